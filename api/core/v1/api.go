@@ -15,8 +15,16 @@ limitations under the License.
 package v1
 
 import (
-    "github.com/aimmatic/aimmatic-go-sdk-placenext/rest"
+    "context"
+    "encoding/json"
+    "errors"
+    "io"
+    "io/ioutil"
+    "log/slog"
+    "net/http"
     "time"
+
+    "github.com/aimmatic/aimmatic-go-sdk-placenext/rest"
 )
 
 type Status struct {
@@ -31,6 +39,13 @@ type Response struct {
 
 const apiVersion = "/v1"
 
+// operation name of each api, use to select the rest.Client timeout
+const (
+    OperationPointImport    = "PointImport"
+    OperationGeometryImport = "GeometryImport"
+    OperationGetNSS         = "GetNSS"
+)
+
 // CoreV1 provides access to all api of aimmatic service
 type CoreV1 interface {
     placeNext
@@ -39,12 +54,19 @@ type CoreV1 interface {
 
 type placeNext interface {
     PointImport([]*PointJSON) (*Response, error)
+    PointImportContext(context.Context, []*PointJSON) (*Response, error)
     GeometryImport(*GeometryCollection) (*Response, error)
+    GeometryImportContext(context.Context, *GeometryCollection) (*Response, error)
+    PointImportChunks(context.Context, []*PointJSON, ChunkOptions) (*ImportReport, error)
+    GeometryImportChunks(context.Context, *GeometryCollection, ChunkOptions) (*ImportReport, error)
+    PresignGeometryImport(context.Context, time.Duration, ...rest.PresignOption) (string, error)
 }
 
 type insights interface {
     GetNSS() (*NSSResponse, error)
+    GetNSSContext(ctx context.Context) (*NSSResponse, error)
     GetNSSByRange(start, end time.Time) (*NSSResponse, error)
+    GetNSSByRangeContext(ctx context.Context, start, end time.Time) (*NSSResponse, error)
 }
 
 type coreV1 struct {
@@ -55,3 +77,75 @@ type coreV1 struct {
 func NewCoreV1(client *rest.Client) CoreV1 {
     return &coreV1{client: client}
 }
+
+// newRequest create a new request bound to the given context and tag it with the
+// operation name so the rest.Client can apply the operation timeout
+func newRequest(ctx context.Context, operation, method, url string, body io.Reader) (*http.Request, error) {
+    return http.NewRequestWithContext(rest.WithOperation(ctx, operation), method, url, body)
+}
+
+// newStreamingRequest create a new request which body is v encoded as json while the
+// request is sent, see rest.NewStreamingRequest. The body is the json.Marshal output
+// so it does not end with a new line.
+func newStreamingRequest(ctx context.Context, scheme rest.SignatureScheme, operation, method, url string, v interface{}) (*http.Request, error) {
+    return rest.NewStreamingRequest(rest.WithOperation(ctx, operation), method, url, scheme, func(w io.Writer) error {
+        b, err := json.Marshal(v)
+        if err != nil {
+            return wrapError(InvalidPayload, "encode "+operation, err)
+        }
+        _, err = w.Write(b)
+        return err
+    })
+}
+
+// do send the request through the rest.Client and decode the response body into
+// out. It return false if the response does not have a body to decode. An unsuccessful
+// http status is returned as *APIError.
+func (p *coreV1) do(req *http.Request, out interface{}) (decoded bool, err error) {
+    var httpResp *http.Response
+    if httpResp, err = p.client.Do(req); err != nil {
+        return
+    }
+    defer httpResp.Body.Close()
+    if logger := p.client.Logger(); logger != nil {
+        defer func() { logResult(logger, req, httpResp.StatusCode, out, err) }()
+    }
+    if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+        body, _ := ioutil.ReadAll(io.LimitReader(httpResp.Body, maxErrorBody))
+        return false, newAPIError(httpResp.StatusCode, body)
+    }
+    var body []byte
+    if body, err = ioutil.ReadAll(httpResp.Body); err != nil || len(body) == 0 {
+        return
+    }
+    decoded = true
+    err = json.Unmarshal(body, out)
+    return
+}
+
+// logResult log the outcome of an api call with the request id of the response status
+func logResult(logger *slog.Logger, req *http.Request, httpStatus int, out interface{}, err error) {
+    var status *Status
+    switch v := out.(type) {
+    case *Response:
+        status = v.Status
+    case *NSSResponse:
+        status = v.Status
+    }
+    attrs := []slog.Attr{
+        slog.String("operation", rest.OperationFromContext(req.Context())),
+        slog.String("endpoint", rest.Endpoint(req)),
+        slog.Int("status", httpStatus),
+    }
+    var apiErr *APIError
+    if errors.As(err, &apiErr) && apiErr.RequestId != "" {
+        attrs = append(attrs, slog.String("requestId", apiErr.RequestId))
+    } else if status != nil && status.RequestId != "" {
+        attrs = append(attrs, slog.String("requestId", status.RequestId))
+    }
+    if err != nil {
+        logger.LogAttrs(req.Context(), slog.LevelWarn, "placenext call failed", append(attrs, slog.String("error", err.Error()))...)
+        return
+    }
+    logger.LogAttrs(req.Context(), slog.LevelInfo, "placenext call", attrs...)
+}
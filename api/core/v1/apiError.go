@@ -0,0 +1,117 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strconv"
+)
+
+// class of api error, use with errors.Is to branch on the kind of failure
+var (
+    // the server reject the request as malformed (HTTP 400)
+    ErrBadRequest = errors.New("placenext: bad request")
+    // the api key or signature is not accepted (HTTP 401)
+    ErrUnauthorized = errors.New("placenext: unauthorized")
+    // the api key is not allowed to access the resource (HTTP 403)
+    ErrForbidden = errors.New("placenext: forbidden")
+    // the resource does not exist (HTTP 404)
+    ErrNotFound = errors.New("placenext: not found")
+    // the request body exceed the server limit (HTTP 413)
+    ErrPayloadTooLarge = errors.New("placenext: payload too large")
+    // the request budget of the api key is exhausted (HTTP 429)
+    ErrRateLimited = errors.New("placenext: rate limited")
+    // the server failed to process the request (HTTP 5xx)
+    ErrServerError = errors.New("placenext: server error")
+    // any other unsuccessful response
+    ErrUnexpectedStatus = errors.New("placenext: unexpected status")
+)
+
+// APIError is returned when the placenext api server answer with an unsuccessful
+// http status. The error match one of the error class with errors.Is and can be
+// extracted with errors.As.
+type APIError struct {
+    // HTTP status code of the response
+    HTTPStatus int
+    // Code of the response Status body if any
+    Code int
+    // Message of the response Status body if any
+    Message string
+    // RequestId of the response Status body if any
+    RequestId string
+    // Body is the raw response body
+    Body []byte
+}
+
+// maximum size of the response body kept in APIError
+const maxErrorBody = 1 << 20
+
+// Error implement error interface
+func (e *APIError) Error() string {
+    msg := "placenext: http " + strconv.Itoa(e.HTTPStatus)
+    if e.Code != 0 {
+        msg += " code [" + strconv.Itoa(e.Code) + "]"
+    }
+    if e.Message != "" {
+        msg += ": " + e.Message
+    } else if text := http.StatusText(e.HTTPStatus); text != "" {
+        msg += ": " + text
+    }
+    if e.RequestId != "" {
+        msg += " (request id " + e.RequestId + ")"
+    }
+    return msg
+}
+
+// Unwrap return the error class of the http status
+func (e *APIError) Unwrap() error {
+    switch {
+    case e.HTTPStatus == http.StatusBadRequest:
+        return ErrBadRequest
+    case e.HTTPStatus == http.StatusUnauthorized:
+        return ErrUnauthorized
+    case e.HTTPStatus == http.StatusForbidden:
+        return ErrForbidden
+    case e.HTTPStatus == http.StatusNotFound:
+        return ErrNotFound
+    case e.HTTPStatus == http.StatusRequestEntityTooLarge:
+        return ErrPayloadTooLarge
+    case e.HTTPStatus == http.StatusTooManyRequests:
+        return ErrRateLimited
+    case e.HTTPStatus >= 500:
+        return ErrServerError
+    }
+    return ErrUnexpectedStatus
+}
+
+// newAPIError create an APIError from the given status and body. The body is
+// decoded as a Response or a bare Status when possible to fill the Status fields.
+func newAPIError(statusCode int, body []byte) *APIError {
+    apiErr := &APIError{HTTPStatus: statusCode, Body: body}
+    if len(body) == 0 {
+        return apiErr
+    }
+    status := &Status{}
+    resp := &Response{}
+    if json.Unmarshal(body, resp) == nil && resp.Status != nil {
+        status = resp.Status
+    } else if json.Unmarshal(body, status) != nil {
+        return apiErr
+    }
+    apiErr.Code = status.Code
+    apiErr.Message = status.Message
+    apiErr.RequestId = status.RequestId
+    return apiErr
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "io/ioutil"
+    "log/slog"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/aimmatic/aimmatic-go-sdk-placenext/rest"
+    "github.com/aimmatic/aimmatic-go-sdk-placenext/telemetry"
+)
+
+const (
+    apiKey    = "UOCMBvhRFLwxDhUFdDeK2QpfvV80Og"
+    secretKey = "dMAMNw6HE60xDhV0SWZNsVZSVW91culvEXBFLE76ij62wsZXXqI+aQ"
+)
+
+// newTestCoreV1 create a coreV1 that send all request to the given handler
+func newTestCoreV1(t *testing.T, handler http.HandlerFunc) *coreV1 {
+    server := httptest.NewServer(handler)
+    t.Cleanup(server.Close)
+    t.Setenv(rest.PLACENEXT_ADDRESS, server.URL)
+    config, err := rest.NewConfig(apiKey, secretKey)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return NewCoreV1(rest.NewRestClient(config)).(*coreV1)
+}
+
+func TestPointImportBody(t *testing.T) {
+    points := []*PointJSON{{Latitude: 1, Longitude: 2}, {Latitude: 3, Longitude: 4}}
+    expect, _ := json.Marshal(points)
+    api := newTestCoreV1(t, func(w http.ResponseWriter, r *http.Request) {
+        // the body and its md5 are the ones of the json.Marshal output
+        b, _ := ioutil.ReadAll(r.Body)
+        if !bytes.Equal(b, expect) {
+            t.Errorf("expect body %s got %q", expect, b)
+        }
+        marshaled, _ := http.NewRequest(http.MethodPost, "http://api.aimmatic.com", bytes.NewReader(expect))
+        marshaled.Header = r.Header.Clone()
+        if _, _, _, contentMD5, _ := rest.ComputeSignature(marshaled, nil); r.Header.Get(rest.ContentMD5) != contentMD5 {
+            t.Error("expect content md5", contentMD5, "got", r.Header.Get(rest.ContentMD5))
+        }
+    })
+    if _, err := api.PointImport(points); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestAPIError(t *testing.T) {
+    api := newTestCoreV1(t, func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusRequestEntityTooLarge)
+        w.Write([]byte(`{"status":{"code":413,"message":"too many points","requestId":"req-1"}}`))
+    })
+    resp, err := api.PointImport([]*PointJSON{{Latitude: 1, Longitude: 2}})
+    if resp != nil {
+        t.Error("expect nil response got", resp)
+    }
+    if !errors.Is(err, ErrPayloadTooLarge) {
+        t.Fatal("expect payload too large got", err)
+    }
+    var apiErr *APIError
+    if !errors.As(err, &apiErr) {
+        t.Fatal("expect APIError got", err)
+    }
+    if apiErr.HTTPStatus != 413 || apiErr.Code != 413 || apiErr.Message != "too many points" || apiErr.RequestId != "req-1" {
+        t.Error("unexpected api error", apiErr)
+    }
+    // status without body
+    api = newTestCoreV1(t, func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusServiceUnavailable)
+    })
+    if _, err = api.GetNSS(); !errors.Is(err, ErrServerError) {
+        t.Error("expect server error got", err)
+    }
+    // success without body
+    api = newTestCoreV1(t, func(w http.ResponseWriter, r *http.Request) {})
+    if resp, err = api.GeometryImport(&GeometryCollection{}); err != nil {
+        t.Error(err)
+    } else if resp.Status.Message != "OK" {
+        t.Error("expect OK status got", resp.Status)
+    }
+}
+
+func TestLogRequestId(t *testing.T) {
+    api := newTestCoreV1(t, func(w http.ResponseWriter, r *http.Request) {
+        w.Write([]byte(`{"status":{"code":200,"message":"OK","requestId":"req-7"}}`))
+    })
+    var buf bytes.Buffer
+    api.client.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+    if _, err := api.PointImport([]*PointJSON{{Latitude: 1, Longitude: 2}}); err != nil {
+        t.Fatal(err)
+    }
+    if !strings.Contains(buf.String(), `msg="placenext call" operation=PointImport`) || !strings.Contains(buf.String(), "requestId=req-7") {
+        t.Error("expect call record with request id got", buf.String())
+    }
+}
+
+func TestPointsIngestedMetric(t *testing.T) {
+    api := newTestCoreV1(t, func(w http.ResponseWriter, r *http.Request) {})
+    exporter := telemetry.NewMemoryExporter()
+    api.client.SetMeter(exporter)
+    api.client.SetTracer(exporter)
+    if _, err := api.PointImport([]*PointJSON{{Latitude: 1, Longitude: 2}, {Latitude: 3, Longitude: 4}}); err != nil {
+        t.Fatal(err)
+    }
+    if sum := exporter.Sum(rest.MetricPointsIngested); sum != 2 {
+        t.Error("expect 2 points ingested got", sum)
+    }
+    if spans := exporter.Spans(); len(spans) != 1 || spans[0].Name != "POST /v1/placeNextIngest/PointImport" {
+        t.Error("expect a span named after the endpoint got", spans)
+    }
+}
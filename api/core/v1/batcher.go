@@ -0,0 +1,316 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "sync"
+    "time"
+)
+
+// ErrBatcherClosed is returned when a point is added to a closed Batcher
+var ErrBatcherClosed = errors.New("batcher is closed")
+
+// ErrBatchDropped is reported when a batch is dropped because the upload queue is full
+var ErrBatchDropped = errors.New("batch dropped, upload queue is full")
+
+// Backpressure define what a Batcher does when its upload queue is full
+type Backpressure int
+
+const (
+    // BackpressureBlock block Add until the queue has room for the batch
+    BackpressureBlock Backpressure = iota
+    // BackpressureDrop drop the batch and report ErrBatchDropped
+    BackpressureDrop
+)
+
+// default batcher options
+const (
+    DefaultBatchMaxPoints   = 1000
+    DefaultBatchMaxBytes    = 1 << 20
+    DefaultBatchMaxLatency  = time.Second
+    DefaultBatchMaxInFlight = 2
+)
+
+// BatcherOptions configure a Batcher, a zero value use the default
+type BatcherOptions struct {
+    // MaxPoints flush the batch once it hold the given number of points
+    MaxPoints int
+    // MaxBytes flush the batch once its json encoded size reach the given number of bytes
+    MaxBytes int
+    // MaxLatency flush the batch once its first point wait for the given duration
+    MaxLatency time.Duration
+    // MaxInFlight is the maximum number of concurrent uploads
+    MaxInFlight int
+    // MaxQueued is the number of flushed batches that can wait for an upload slot
+    // before backpressure kick in. Default to MaxInFlight.
+    MaxQueued int
+    // Backpressure mode when the queue is full
+    Backpressure Backpressure
+    // OnResult is called with the points of each batch successfully uploaded
+    OnResult func(points []*PointJSON, resp *Response)
+    // OnError is called with the points of each batch that failed to upload or was dropped
+    OnError func(points []*PointJSON, err error)
+}
+
+// Batcher collect points one at a time and upload them with PointImport in batches.
+// A batch is flushed when it reach MaxPoints or MaxBytes or when its oldest point
+// has waited MaxLatency. A Batcher is safe for concurrent use.
+type Batcher struct {
+    api  CoreV1
+    opts BatcherOptions
+
+    // context of the uploads, canceled when Close give up draining
+    ctx    context.Context
+    cancel context.CancelFunc
+
+    mu      sync.Mutex
+    points  []*PointJSON
+    size    int
+    timer   *time.Timer
+    closed  bool
+    pending int
+    waiters []chan struct{}
+
+    queue   chan []*PointJSON
+    stop    chan struct{}
+    workers sync.WaitGroup
+}
+
+// NewBatcher create a Batcher that upload points through the given api
+func NewBatcher(api CoreV1, opts BatcherOptions) *Batcher {
+    if opts.MaxPoints <= 0 {
+        opts.MaxPoints = DefaultBatchMaxPoints
+    }
+    if opts.MaxBytes <= 0 {
+        opts.MaxBytes = DefaultBatchMaxBytes
+    }
+    if opts.MaxLatency <= 0 {
+        opts.MaxLatency = DefaultBatchMaxLatency
+    }
+    if opts.MaxInFlight <= 0 {
+        opts.MaxInFlight = DefaultBatchMaxInFlight
+    }
+    if opts.MaxQueued <= 0 {
+        opts.MaxQueued = opts.MaxInFlight
+    }
+    b := &Batcher{
+        api:   api,
+        opts:  opts,
+        queue: make(chan []*PointJSON, opts.MaxQueued),
+        stop:  make(chan struct{}),
+    }
+    b.ctx, b.cancel = context.WithCancel(context.Background())
+    b.workers.Add(opts.MaxInFlight)
+    for i := 0; i < opts.MaxInFlight; i++ {
+        go b.work()
+    }
+    return b
+}
+
+// Add a point to the current batch. In blocking mode Add wait until the upload
+// queue has room when the point complete a batch.
+func (b *Batcher) Add(point *PointJSON) error {
+    return b.AddContext(context.Background(), point)
+}
+
+// AddContext add a point to the current batch. In blocking mode the wait for
+// the upload queue is bound to the given context.
+func (b *Batcher) AddContext(ctx context.Context, point *PointJSON) error {
+    encoded, err := json.Marshal(point)
+    if err != nil {
+        return wrapError(InvalidPayload, "encode point", err)
+    }
+    b.mu.Lock()
+    if b.closed {
+        b.mu.Unlock()
+        return ErrBatcherClosed
+    }
+    b.points = append(b.points, point)
+    // one more byte for the separator
+    b.size += len(encoded) + 1
+    var batch []*PointJSON
+    if len(b.points) >= b.opts.MaxPoints || b.size+1 >= b.opts.MaxBytes {
+        batch = b.takeLocked()
+    } else if b.timer == nil {
+        b.timer = afterFunc(b.opts.MaxLatency, b.flushTimer)
+    }
+    b.mu.Unlock()
+    if batch != nil {
+        return b.enqueue(ctx, batch)
+    }
+    return nil
+}
+
+// Flush upload the current batch and wait until every batch is uploaded or the
+// context is done. The current batch wait for room in the queue whatever the
+// backpressure mode.
+func (b *Batcher) Flush(ctx context.Context) error {
+    b.mu.Lock()
+    batch := b.takeLocked()
+    b.mu.Unlock()
+    if batch != nil {
+        if err := b.send(ctx, batch); err != nil {
+            return err
+        }
+    }
+    return b.wait(ctx)
+}
+
+// Close stop accepting points, upload the remaining ones and release the upload
+// workers. The remaining points wait for room in the queue whatever the backpressure
+// mode. If the context is done before the uploads complete, in-flight uploads are
+// canceled and the context error is returned.
+func (b *Batcher) Close(ctx context.Context) error {
+    b.mu.Lock()
+    if b.closed {
+        b.mu.Unlock()
+        return ErrBatcherClosed
+    }
+    b.closed = true
+    batch := b.takeLocked()
+    b.mu.Unlock()
+    var err error
+    if batch != nil {
+        err = b.send(ctx, batch)
+    }
+    if err == nil {
+        err = b.wait(ctx)
+    }
+    if err != nil {
+        b.cancel()
+    }
+    close(b.stop)
+    b.workers.Wait()
+    b.cancel()
+    return err
+}
+
+// takeLocked remove the current batch, the batch is pending until it is uploaded.
+// The caller must hold the lock.
+func (b *Batcher) takeLocked() []*PointJSON {
+    if b.timer != nil {
+        b.timer.Stop()
+        b.timer = nil
+    }
+    if len(b.points) == 0 {
+        return nil
+    }
+    batch := b.points
+    b.points = nil
+    b.size = 0
+    b.pending++
+    return batch
+}
+
+// afterFunc start the latency timer of a batch, it is replaced in tests
+var afterFunc = time.AfterFunc
+
+// flushTimer flush the current batch once it wait for MaxLatency
+func (b *Batcher) flushTimer() {
+    b.mu.Lock()
+    batch := b.takeLocked()
+    b.mu.Unlock()
+    if batch != nil {
+        b.enqueue(b.ctx, batch)
+    }
+}
+
+// enqueue hand the batch to the upload workers according to the backpressure mode
+func (b *Batcher) enqueue(ctx context.Context, batch []*PointJSON) error {
+    if b.opts.Backpressure == BackpressureDrop {
+        select {
+        case b.queue <- batch:
+            return nil
+        default:
+            b.done(batch, nil, ErrBatchDropped)
+            return ErrBatchDropped
+        }
+    }
+    return b.send(ctx, batch)
+}
+
+// send wait until the queue has room for the batch or the context is done
+func (b *Batcher) send(ctx context.Context, batch []*PointJSON) error {
+    select {
+    case b.queue <- batch:
+        return nil
+    case <-ctx.Done():
+        b.done(batch, nil, ctx.Err())
+        return ctx.Err()
+    case <-b.stop:
+        b.done(batch, nil, ErrBatcherClosed)
+        return ErrBatcherClosed
+    }
+}
+
+// work upload the queued batches until the batcher is closed
+func (b *Batcher) work() {
+    defer b.workers.Done()
+    for {
+        select {
+        case batch := <-b.queue:
+            resp, err := b.api.PointImportContext(b.ctx, batch)
+            b.done(batch, resp, err)
+        case <-b.stop:
+            // report the batches left behind when Close give up draining
+            for {
+                select {
+                case batch := <-b.queue:
+                    b.done(batch, nil, context.Canceled)
+                default:
+                    return
+                }
+            }
+        }
+    }
+}
+
+// done report the result of a batch and release it from the pending batches
+func (b *Batcher) done(batch []*PointJSON, resp *Response, err error) {
+    if err != nil {
+        if b.opts.OnError != nil {
+            b.opts.OnError(batch, err)
+        }
+    } else if b.opts.OnResult != nil {
+        b.opts.OnResult(batch, resp)
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if b.pending--; b.pending == 0 {
+        for _, waiter := range b.waiters {
+            close(waiter)
+        }
+        b.waiters = nil
+    }
+}
+
+// wait until there is no pending batch or the context is done
+func (b *Batcher) wait(ctx context.Context) error {
+    b.mu.Lock()
+    if b.pending == 0 {
+        b.mu.Unlock()
+        return nil
+    }
+    waiter := make(chan struct{})
+    b.waiters = append(b.waiters, waiter)
+    b.mu.Unlock()
+    select {
+    case <-waiter:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
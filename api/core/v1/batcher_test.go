@@ -0,0 +1,201 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "runtime"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestBatcher(t *testing.T) {
+    // the latency timer is fired by the test
+    var latency time.Duration
+    var fire func()
+    afterFunc = func(d time.Duration, f func()) *time.Timer {
+        latency, fire = d, f
+        return time.NewTimer(time.Hour)
+    }
+    defer func() { afterFunc = time.AfterFunc }()
+    var mu sync.Mutex
+    var sizes []int
+    api := newTestCoreV1(t, func(w http.ResponseWriter, r *http.Request) {
+        var points []*PointJSON
+        json.NewDecoder(r.Body).Decode(&points)
+        mu.Lock()
+        sizes = append(sizes, len(points))
+        mu.Unlock()
+    })
+    var uploaded int
+    batcher := NewBatcher(api, BatcherOptions{
+        MaxPoints:   3,
+        MaxLatency:  20 * time.Millisecond,
+        MaxInFlight: 1,
+        OnResult: func(points []*PointJSON, resp *Response) {
+            mu.Lock()
+            uploaded += len(points)
+            mu.Unlock()
+        },
+        OnError: func(points []*PointJSON, err error) {
+            t.Error("unexpected upload error", err)
+        },
+    })
+    for i := 0; i < 7; i++ {
+        if err := batcher.Add(&PointJSON{Latitude: float64(i)}); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := batcher.Flush(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    mu.Lock()
+    if uploaded != 7 || len(sizes) != 3 || sizes[0] != 3 || sizes[1] != 3 || sizes[2] != 1 {
+        t.Error("unexpected batches", sizes, "uploaded", uploaded)
+    }
+    mu.Unlock()
+    // latency flush
+    fire = nil
+    batcher.Add(&PointJSON{})
+    if fire == nil || latency != 20*time.Millisecond {
+        t.Fatal("expect latency timer got", latency)
+    }
+    mu.Lock()
+    if uploaded != 7 {
+        t.Error("expect no upload before the latency timer got", uploaded)
+    }
+    mu.Unlock()
+    fire()
+    // wait for the batch enqueued by the timer without flushing another one
+    if err := batcher.wait(context.Background()); err != nil {
+        t.Fatal(err)
+    }
+    mu.Lock()
+    if uploaded != 8 {
+        t.Error("expect latency flush got", uploaded)
+    }
+    mu.Unlock()
+    if err := batcher.Close(context.Background()); err != nil {
+        t.Error(err)
+    }
+    if err := batcher.Add(&PointJSON{}); err != ErrBatcherClosed {
+        t.Error("expect closed error got", err)
+    }
+}
+
+func TestBatcherDrop(t *testing.T) {
+    started, release := make(chan struct{}, 1), make(chan struct{})
+    api := newTestCoreV1(t, func(w http.ResponseWriter, r *http.Request) {
+        started <- struct{}{}
+        <-release
+    })
+    var mu sync.Mutex
+    var dropped int
+    batcher := NewBatcher(api, BatcherOptions{
+        MaxPoints:    1,
+        MaxInFlight:  1,
+        MaxQueued:    1,
+        Backpressure: BackpressureDrop,
+        OnError: func(points []*PointJSON, err error) {
+            mu.Lock()
+            defer mu.Unlock()
+            if err == ErrBatchDropped {
+                dropped++
+            }
+        },
+    })
+    // the first batch is in flight and the second is queued
+    batcher.Add(&PointJSON{})
+    <-started
+    batcher.Add(&PointJSON{})
+    if err := batcher.Add(&PointJSON{}); err != ErrBatchDropped {
+        t.Error("expect dropped batch got", err)
+    }
+    close(release)
+    if err := batcher.Close(context.Background()); err != nil {
+        t.Error(err)
+    }
+    if dropped != 1 {
+        t.Error("expect 1 dropped batch got", dropped)
+    }
+}
+
+func TestBatcherDropDrain(t *testing.T) {
+    for _, name := range []string{"flush", "close"} {
+        started, release := make(chan struct{}, 1), make(chan struct{})
+        api := newTestCoreV1(t, func(w http.ResponseWriter, r *http.Request) {
+            select {
+            case started <- struct{}{}:
+            default:
+            }
+            <-release
+        })
+        var mu sync.Mutex
+        var uploaded int
+        batcher := NewBatcher(api, BatcherOptions{
+            MaxPoints:    2,
+            MaxInFlight:  1,
+            MaxQueued:    1,
+            Backpressure: BackpressureDrop,
+            OnResult: func(points []*PointJSON, resp *Response) {
+                mu.Lock()
+                uploaded += len(points)
+                mu.Unlock()
+            },
+            OnError: func(points []*PointJSON, err error) {
+                t.Error(name, "unexpected upload error", err)
+            },
+        })
+        // a batch in flight, a batch queued and a partial batch
+        for i := 0; i < 5; i++ {
+            if err := batcher.Add(&PointJSON{}); err != nil {
+                t.Fatal(err)
+            }
+            if i == 1 {
+                <-started
+            }
+        }
+        drain, draining := batcher.Flush, func() bool { return len(batcher.points) == 0 }
+        if name == "close" {
+            drain, draining = batcher.Close, func() bool { return batcher.closed }
+        }
+        errc := make(chan error, 1)
+        go func() { errc <- drain(context.Background()) }()
+        // release the upload once the partial batch wait for room in the full queue
+        for {
+            batcher.mu.Lock()
+            taken := draining()
+            batcher.mu.Unlock()
+            if taken {
+                break
+            }
+            runtime.Gosched()
+        }
+        close(release)
+        if err := <-errc; err != nil {
+            t.Error("expect", name, "to drain got", err)
+        }
+        mu.Lock()
+        if uploaded != 5 {
+            t.Error("expect", name, "to upload 5 points got", uploaded)
+        }
+        mu.Unlock()
+        if name == "flush" {
+            batcher.Close(context.Background())
+        }
+    }
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "sort"
+    "strconv"
+    "sync"
+
+    "github.com/aimmatic/aimmatic-go-sdk-placenext/rest"
+)
+
+// default chunk options
+const (
+    DefaultChunkMaxItems       = 10000
+    DefaultChunkMaxBytes       = 4 << 20
+    DefaultChunkMaxConcurrency = 4
+)
+
+// ChunkOptions control how an import is split into chunks, a zero value use the default
+type ChunkOptions struct {
+    // MaxItems is the maximum number of points or geometries of a chunk
+    MaxItems int
+    // MaxBytes is the maximum json encoded size of a chunk. A single item larger
+    // than MaxBytes is sent alone.
+    MaxBytes int
+    // MaxConcurrency is the maximum number of chunks uploaded at the same time
+    MaxConcurrency int
+}
+
+// ChunkResult is the result of a chunk covering the items from index Start
+// (inclusive) to End (exclusive) of the input
+type ChunkResult struct {
+    Start    int
+    End      int
+    Response *Response
+    Err      error
+}
+
+// ImportReport report the result of every chunk of an import in input order.
+// A chunk rejected with ErrPayloadTooLarge is split in two and reported as two
+// separate chunks.
+type ImportReport struct {
+    Chunks []*ChunkResult
+}
+
+// Failed return the chunks that failed to upload
+func (r *ImportReport) Failed() []*ChunkResult {
+    var failed []*ChunkResult
+    for _, chunk := range r.Chunks {
+        if chunk.Err != nil {
+            failed = append(failed, chunk)
+        }
+    }
+    return failed
+}
+
+// Err return an ImportIncomplete error caused by the first failed chunk or nil
+// if every chunk succeed
+func (r *ImportReport) Err() error {
+    failed := r.Failed()
+    if len(failed) == 0 {
+        return nil
+    }
+    return wrapError(ImportIncomplete, strconv.Itoa(len(failed))+" of "+strconv.Itoa(len(r.Chunks))+" chunks failed", failed[0].Err)
+}
+
+// PointImportChunks split the points into chunks under the option limits and upload
+// them concurrently. The returned error is the report error, the report is always returned.
+func (p *coreV1) PointImportChunks(ctx context.Context, lms []*PointJSON, opts ChunkOptions) (*ImportReport, error) {
+    upload := func(ctx context.Context, start, end int) (*Response, error) {
+        return p.PointImportContext(ctx, lms[start:end])
+    }
+    return importChunks(ctx, len(lms), func(i int) interface{} { return lms[i] }, upload, opts)
+}
+
+// GeometryImportChunks split the geometry collection into chunks under the option limits
+// and upload them concurrently. Every chunk is a collection of the same type.
+func (p *coreV1) GeometryImportChunks(ctx context.Context, geometryCollection *GeometryCollection, opts ChunkOptions) (*ImportReport, error) {
+    if geometryCollection == nil {
+        return nil, newError(InvalidPayload, "geometry collection is nil")
+    }
+    geometries := geometryCollection.Geometries
+    upload := func(ctx context.Context, start, end int) (*Response, error) {
+        return p.GeometryImportContext(ctx, &GeometryCollection{Type: geometryCollection.Type, Geometries: geometries[start:end]})
+    }
+    return importChunks(ctx, len(geometries), func(i int) interface{} { return geometries[i] }, upload, opts)
+}
+
+// importChunks split n items into chunks and upload them with bounded concurrency
+func importChunks(ctx context.Context, n int, item func(int) interface{}, upload func(context.Context, int, int) (*Response, error), opts ChunkOptions) (*ImportReport, error) {
+    if opts.MaxItems <= 0 {
+        opts.MaxItems = DefaultChunkMaxItems
+    }
+    if opts.MaxBytes <= 0 {
+        opts.MaxBytes = DefaultChunkMaxBytes
+    }
+    if opts.MaxConcurrency <= 0 {
+        opts.MaxConcurrency = DefaultChunkMaxConcurrency
+    }
+    bounds, err := splitChunks(n, item, opts)
+    if err != nil {
+        return nil, err
+    }
+    report := &ImportReport{}
+    key := rest.IdempotencyKeyFromContext(ctx)
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    sem := make(chan struct{}, opts.MaxConcurrency)
+    var run func(start, end int)
+    run = func(start, end int) {
+        defer wg.Done()
+        result := &ChunkResult{Start: start, End: end}
+        select {
+        case sem <- struct{}{}:
+            chunkCtx := ctx
+            if key != "" {
+                // derive a stable key of the chunk from the caller key
+                chunkCtx = rest.WithIdempotencyKey(ctx, key+"-"+strconv.Itoa(start)+"-"+strconv.Itoa(end))
+            }
+            result.Response, result.Err = upload(chunkCtx, start, end)
+            <-sem
+        case <-ctx.Done():
+            result.Err = ctx.Err()
+        }
+        if errors.Is(result.Err, ErrPayloadTooLarge) && end-start > 1 {
+            mid := start + (end-start)/2
+            wg.Add(2)
+            go run(start, mid)
+            go run(mid, end)
+            return
+        }
+        mu.Lock()
+        report.Chunks = append(report.Chunks, result)
+        mu.Unlock()
+    }
+    wg.Add(len(bounds))
+    for _, b := range bounds {
+        go run(b[0], b[1])
+    }
+    wg.Wait()
+    sort.Slice(report.Chunks, func(i, j int) bool { return report.Chunks[i].Start < report.Chunks[j].Start })
+    return report, report.Err()
+}
+
+// splitChunks return the [start, end) bounds of each chunk
+func splitChunks(n int, item func(int) interface{}, opts ChunkOptions) ([][2]int, error) {
+    var bounds [][2]int
+    start, size := 0, 2
+    for i := 0; i < n; i++ {
+        encoded, err := json.Marshal(item(i))
+        if err != nil {
+            return nil, wrapError(InvalidPayload, "encode item "+strconv.Itoa(i), err)
+        }
+        itemSize := len(encoded) + 1
+        if i > start && (i-start >= opts.MaxItems || size+itemSize > opts.MaxBytes) {
+            bounds = append(bounds, [2]int{start, i})
+            start, size = i, 2
+        }
+        size += itemSize
+    }
+    if start < n {
+        bounds = append(bounds, [2]int{start, n})
+    }
+    return bounds, nil
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "testing"
+)
+
+func TestPointImportChunks(t *testing.T) {
+    api := newTestCoreV1(t, func(w http.ResponseWriter, r *http.Request) {
+        var points []*PointJSON
+        json.NewDecoder(r.Body).Decode(&points)
+        switch {
+        case len(points) > 2:
+            w.WriteHeader(http.StatusRequestEntityTooLarge)
+        case points[0].Latitude == 6:
+            w.WriteHeader(http.StatusBadRequest)
+        }
+    })
+    points := make([]*PointJSON, 10)
+    for i := range points {
+        points[i] = &PointJSON{Latitude: float64(i)}
+    }
+    report, err := api.PointImportChunks(context.Background(), points, ChunkOptions{MaxItems: 4, MaxConcurrency: 2})
+    if !errors.Is(err, ErrBadRequest) {
+        t.Fatal("expect bad request got", err)
+    }
+    var codeErr *Error
+    if !errors.As(err, &codeErr) || codeErr.Code != ImportIncomplete {
+        t.Error("expect import incomplete got", err)
+    }
+    // 4 + 4 + 2 then chunks of 4 are split in 2 + 2
+    expects := [][2]int{{0, 2}, {2, 4}, {4, 6}, {6, 8}, {8, 10}}
+    if len(report.Chunks) != len(expects) {
+        t.Fatal("unexpected chunks", len(report.Chunks))
+    }
+    for i, chunk := range report.Chunks {
+        if chunk.Start != expects[i][0] || chunk.End != expects[i][1] {
+            t.Error("chunk", i, "expect", expects[i], "got", chunk.Start, chunk.End)
+        }
+    }
+    if failed := report.Failed(); len(failed) != 1 || failed[0].Start != 6 {
+        t.Error("expect chunk 6-8 to fail got", failed)
+    }
+}
+
+func TestGeometryImportChunksNil(t *testing.T) {
+    api := newTestCoreV1(t, func(w http.ResponseWriter, r *http.Request) {
+        t.Error("expect no request got", r.URL)
+    })
+    report, err := api.GeometryImportChunks(context.Background(), nil, ChunkOptions{})
+    if report != nil || !errors.Is(err, &Error{Code: InvalidPayload}) {
+        t.Error("expect invalid payload got", report, err)
+    }
+}
+
+func TestSplitChunks(t *testing.T) {
+    items := []string{"aaaa", "bbbb", "cccccccccccccccc", "d"}
+    // each item cost its encoded size plus a separator
+    bounds, err := splitChunks(len(items), func(i int) interface{} { return items[i] }, ChunkOptions{MaxItems: 10, MaxBytes: 16})
+    if err != nil {
+        t.Fatal(err)
+    }
+    expects := [][2]int{{0, 2}, {2, 3}, {3, 4}}
+    if len(bounds) != len(expects) {
+        t.Fatal("unexpected bounds", bounds)
+    }
+    for i := range bounds {
+        if bounds[i] != expects[i] {
+            t.Error("expect", expects, "got", bounds)
+        }
+    }
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package v1
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strconv"
+
+    "github.com/aimmatic/aimmatic-go-sdk-placenext/rest"
+    "github.com/aimmatic/aimmatic-go-sdk-placenext/wal"
+)
+
+// DurableQueue write each batch of points to a write-ahead log before uploading it
+// with PointImport. A batch stay in the log until the upload succeed, so pending
+// batches survive a crash or an outage and can be uploaded later with Replay. A batch
+// rejected with a non-retryable error is moved to the dead letter log if any and
+// removed from the queue so it does not block the following batches.
+type DurableQueue struct {
+    api        CoreV1
+    log        *wal.Log
+    deadLetter *wal.Log
+}
+
+// NewDurableQueue create a DurableQueue that upload points through the given api
+// and persist them in the given log
+func NewDurableQueue(api CoreV1, log *wal.Log) *DurableQueue {
+    return &DurableQueue{api: api, log: log}
+}
+
+// SetDeadLetter set the log where the batches rejected with a non-retryable error
+// are moved. Without dead letter log, such batches are dropped.
+func (q *DurableQueue) SetDeadLetter(log *wal.Log) {
+    q.deadLetter = log
+}
+
+// Enqueue persist the points then upload them. If the upload fail, the points are
+// kept in the log and the upload error is returned. If the batch is rejected, the
+// returned error match BatchRejected.
+func (q *DurableQueue) Enqueue(ctx context.Context, points []*PointJSON) (*Response, error) {
+    data, err := json.Marshal(points)
+    if err != nil {
+        return nil, wrapError(InvalidPayload, "encode points", err)
+    }
+    seq, err := q.log.Append(data)
+    if err != nil {
+        return nil, err
+    }
+    return q.upload(ctx, wal.Record{Seq: seq, Data: data}, points)
+}
+
+// Replay upload every pending batch in order. It stop at the first failed upload
+// and return the number of batches removed from the log. Rejected batches are
+// removed and replay continue, the returned error then match BatchRejected.
+func (q *DurableQueue) Replay(ctx context.Context) (int, error) {
+    records, err := q.log.Pending()
+    if err != nil {
+        return 0, err
+    }
+    var rejected []error
+    for i, record := range records {
+        var points []*PointJSON
+        if err = json.Unmarshal(record.Data, &points); err != nil {
+            // a batch that cannot be decoded will never upload
+            err = q.reject(record, wrapError(InvalidPayload, "decode batch "+strconv.FormatUint(record.Seq, 10), err))
+        } else {
+            _, err = q.upload(ctx, record, points)
+        }
+        if errors.Is(err, errBatchRejected) {
+            rejected = append(rejected, err)
+        } else if err != nil {
+            return i, err
+        }
+    }
+    return len(records), errors.Join(rejected...)
+}
+
+// errBatchRejected match any error of BatchRejected code
+var errBatchRejected = newError(BatchRejected, "batch rejected")
+
+// upload a batch and acknowledge it. The idempotency key is derived from the log id,
+// the record sequence number and its content, so a batch uploaded again after a
+// restart is recognized by the server while two identical batches are not.
+func (q *DurableQueue) upload(ctx context.Context, record wal.Record, points []*PointJSON) (*Response, error) {
+    sum := sha256.Sum256(record.Data)
+    key := "wal-" + q.log.ID() + "-" + strconv.FormatUint(record.Seq, 10) + "-" + hex.EncodeToString(sum[:4])
+    resp, err := q.api.PointImportContext(rest.WithIdempotencyKey(ctx, key), points)
+    if err != nil {
+        if permanent(err) {
+            return nil, q.reject(record, err)
+        }
+        return nil, err
+    }
+    return resp, q.log.Ack(record.Seq)
+}
+
+// reject move the batch to the dead letter log if any and remove it from the queue.
+// It return a BatchRejected error caused by err.
+func (q *DurableQueue) reject(record wal.Record, err error) error {
+    if q.deadLetter != nil {
+        if _, dlErr := q.deadLetter.Append(record.Data); dlErr != nil {
+            return dlErr
+        }
+    }
+    if ackErr := q.log.Ack(record.Seq); ackErr != nil {
+        return ackErr
+    }
+    return wrapError(BatchRejected, "batch "+strconv.FormatUint(record.Seq, 10)+" removed from the queue", err)
+}
+
+// permanent report whether the upload will fail again, that is the batch is invalid
+// or the api server reply with a 4xx status other than a timeout, a throttle or an
+// authentication error which can be fixed without changing the batch
+func permanent(err error) bool {
+    if errors.Is(err, &Error{Code: InvalidPayload}) {
+        return true
+    }
+    var apiErr *APIError
+    if !errors.As(err, &apiErr) || apiErr.HTTPStatus < 400 || apiErr.HTTPStatus >= 500 {
+        return false
+    }
+    switch apiErr.HTTPStatus {
+    case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests,
+        http.StatusUnauthorized, http.StatusForbidden:
+        return false
+    }
+    return true
+}
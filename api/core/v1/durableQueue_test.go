@@ -0,0 +1,104 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "testing"
+
+    "github.com/aimmatic/aimmatic-go-sdk-placenext/rest"
+    "github.com/aimmatic/aimmatic-go-sdk-placenext/wal"
+)
+
+func TestDurableQueue(t *testing.T) {
+    down := true
+    var keys []string
+    api := newTestCoreV1(t, func(w http.ResponseWriter, r *http.Request) {
+        keys = append(keys, r.Header.Get(rest.XPlacenextIdempotencyKey))
+        if down {
+            w.WriteHeader(http.StatusServiceUnavailable)
+        }
+    })
+    dir := t.TempDir()
+    log, err := wal.Open(dir, wal.Options{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    queue := NewDurableQueue(api, log)
+    if _, err = queue.Enqueue(context.Background(), []*PointJSON{{Latitude: 1}}); !errors.Is(err, ErrServerError) {
+        t.Fatal("expect server error got", err)
+    }
+    log.Close()
+    // restart and replay once the server is back
+    down = false
+    if log, err = wal.Open(dir, wal.Options{}); err != nil {
+        t.Fatal(err)
+    }
+    defer log.Close()
+    queue = NewDurableQueue(api, log)
+    if n, err := queue.Replay(context.Background()); err != nil || n != 1 {
+        t.Fatal("expect 1 replayed batch got", n, err)
+    }
+    if len(keys) != 2 || keys[0] != keys[1] {
+        t.Error("expect the same idempotency key after restart got", keys)
+    }
+    if records, _ := log.Pending(); len(records) != 0 {
+        t.Error("expect no pending record got", len(records))
+    }
+}
+
+func TestDurableQueueRejected(t *testing.T) {
+    var keys []string
+    api := newTestCoreV1(t, func(w http.ResponseWriter, r *http.Request) {
+        keys = append(keys, r.Header.Get(rest.XPlacenextIdempotencyKey))
+        if len(keys) == 2 {
+            w.WriteHeader(http.StatusBadRequest)
+        }
+    })
+    log, err := wal.Open(t.TempDir(), wal.Options{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer log.Close()
+    deadLetter, err := wal.Open(t.TempDir(), wal.Options{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer deadLetter.Close()
+    queue := NewDurableQueue(api, log)
+    queue.SetDeadLetter(deadLetter)
+    points := []*PointJSON{{Latitude: 1}}
+    // two identical batches are both uploaded
+    if _, err = queue.Enqueue(context.Background(), points); err != nil {
+        t.Fatal(err)
+    }
+    if _, err = queue.Enqueue(context.Background(), points); !errors.Is(err, ErrBadRequest) || !errors.Is(err, &Error{Code: BatchRejected}) {
+        t.Fatal("expect rejected batch got", err)
+    }
+    if len(keys) != 2 || keys[0] == keys[1] {
+        t.Error("expect a distinct idempotency key per batch got", keys)
+    }
+    // the rejected batch does not block the queue
+    if records, _ := log.Pending(); len(records) != 0 {
+        t.Error("expect no pending record got", len(records))
+    }
+    if records, _ := deadLetter.Pending(); len(records) != 1 {
+        t.Error("expect the rejected batch in the dead letter log got", len(records))
+    }
+    if _, err = queue.Enqueue(context.Background(), points); err != nil {
+        t.Error("expect following batch to upload got", err)
+    }
+}
@@ -14,69 +14,87 @@ package v1
 
 import (
     "strconv"
-    "strings"
 )
 
+// ErrorCode identify the kind of error returned by api version 1
+type ErrorCode int
+
 const (
-    InvalidErrorCode = 0
-    InvalidDateRange = 1
+    // the error does not have a specific code
+    InvalidErrorCode ErrorCode = 0
+    // the given date range is invalid
+    InvalidDateRange ErrorCode = 1
+    // the request payload cannot be encoded
+    InvalidPayload ErrorCode = 2
+    // some chunks of an import failed to upload
+    ImportIncomplete ErrorCode = 3
+    // a queued batch was rejected by the api server and removed from the queue
+    BatchRejected ErrorCode = 4
 )
 
-type errorStack struct {
-    code    int
-    message string
+// String return a readable name of the error code
+func (c ErrorCode) String() string {
+    switch c {
+    case InvalidDateRange:
+        return "InvalidDateRange"
+    case InvalidPayload:
+        return "InvalidPayload"
+    case ImportIncomplete:
+        return "ImportIncomplete"
+    case BatchRejected:
+        return "BatchRejected"
+    }
+    return "InvalidErrorCode(" + strconv.Itoa(int(c)) + ")"
 }
 
-func (i *errorStack) Error() string {
-    return "code [" + strconv.FormatInt(int64(i.code), 10) + "]: " + i.message
+// Error is an immutable coded error. An Error match any other Error of the same
+// code with errors.Is, therefore an error created from a sentinel error such as
+// ErrorInvalidDateRange still match the sentinel. The cause if any is available
+// through errors.Unwrap.
+type Error struct {
+    // Code of the error
+    Code ErrorCode
+    // Message describe the error
+    Message string
+    // Err is the underlying cause of the error, it can be nil
+    Err error
 }
 
-func (i *errorStack) StackAfter(message string) {
-    i.message += " " + message
+// Error implement error interface
+func (e *Error) Error() string {
+    msg := "code [" + strconv.Itoa(int(e.Code)) + "]: " + e.Message
+    if e.Err != nil {
+        msg += ": " + e.Err.Error()
+    }
+    return msg
 }
 
-func (i *errorStack) StackBefore(message string) {
-    i.message = message + " " + i.message
+// Unwrap return the cause of the error
+func (e *Error) Unwrap() error {
+    return e.Err
 }
 
-func stackAfter(err error, message string) error {
-    if es, ok := err.(*errorStack); ok {
-        es.StackAfter(message)
-    } else {
-        es = fromError(err)
-        es.StackAfter(message)
-        err = es
-    }
-    return err
+// Is report whether the target is an Error of the same code. An error without
+// a code only match itself.
+func (e *Error) Is(target error) bool {
+    t, ok := target.(*Error)
+    return ok && e.Code != InvalidErrorCode && t.Code == e.Code
 }
 
-func stackBefore(err error, message string) error {
-    if es, ok := err.(*errorStack); ok {
-        es.StackAfter(message)
-    } else {
-        es = fromError(err)
-        es.StackBefore(message)
-        err = es
-    }
-    return err
+// WithDetail return a new error of the same code which message is followed by
+// the given detail. The receiver is left unchanged.
+func (e *Error) WithDetail(detail string) *Error {
+    return &Error{Code: e.Code, Message: e.Message + " " + detail, Err: e.Err}
 }
 
-func fromError(err error) *errorStack {
-    message := err.Error()
-    startInd := strings.IndexByte(message, '[') + 1
-    endInd := strings.IndexByte(message, ']')
-    if startInd > endInd {
-        return &errorStack{code: InvalidErrorCode, message: message}
-    }
-    if code, err := strconv.ParseInt(message[startInd:endInd], 10, 64); err != nil {
-        return &errorStack{code: InvalidErrorCode, message: message}
-    } else {
-        return &errorStack{code: int(code), message: message}
-    }
+// newError create a new coded error
+func newError(code ErrorCode, message string) *Error {
+    return &Error{Code: code, Message: message}
 }
 
-func newError(code int, message string) *errorStack {
-    return &errorStack{code: code, message: message}
+// wrapError create a new coded error caused by the given error
+func wrapError(code ErrorCode, message string, cause error) *Error {
+    return &Error{Code: code, Message: message, Err: cause}
 }
 
 // the given date range is invalid probably the end date is set as before start date
@@ -0,0 +1,61 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+    "errors"
+    "io"
+    "testing"
+    "time"
+)
+
+func TestErrorInvalidDateRange(t *testing.T) {
+    message := ErrorInvalidDateRange.Error()
+    api := newTestCoreV1(t, nil)
+    for i := 0; i < 2; i++ {
+        _, err := api.GetNSSByRange(time.Now(), time.Time{})
+        if !errors.Is(err, ErrorInvalidDateRange) {
+            t.Fatal("expect invalid date range got", err)
+        }
+        var codeErr *Error
+        if !errors.As(err, &codeErr) || codeErr.Code != InvalidDateRange {
+            t.Error("expect coded error got", err)
+        }
+        if err.Error() != message+" start and end time both must be given" {
+            t.Error("unexpected message", err.Error())
+        }
+    }
+    if ErrorInvalidDateRange.Error() != message {
+        t.Error("sentinel error was modified", ErrorInvalidDateRange.Error())
+    }
+    if _, err := api.GetNSSByRange(time.Now(), time.Now().Add(-time.Hour)); err != ErrorInvalidDateRange {
+        t.Error("expect invalid date range got", err)
+    }
+}
+
+func TestErrorWrap(t *testing.T) {
+    err := wrapError(InvalidPayload, "encode points", io.ErrUnexpectedEOF)
+    if !errors.Is(err, io.ErrUnexpectedEOF) {
+        t.Error("expect cause to be unwrapped")
+    }
+    if errors.Is(err, ErrorInvalidDateRange) {
+        t.Error("error of different code must not match")
+    }
+    if err.Error() != "code [2]: encode points: unexpected EOF" {
+        t.Error("unexpected message", err.Error())
+    }
+    if InvalidPayload.String() != "InvalidPayload" {
+        t.Error("unexpected code name", InvalidPayload.String())
+    }
+}
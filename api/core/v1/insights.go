@@ -13,17 +13,15 @@ limitations under the License.
 package v1
 
 import (
+    "context"
     "net/http"
-    "encoding/json"
     "time"
     "strconv"
-
-    "github.com/aimmatic/aimmatic-go-sdk-placenext/rest"
 )
 
 // insightsEndpoint return endpoint path based on the given name
-func insightsEndpoint(config rest.Config, name string) string {
-    return config.GetPlaceNextHost() + apiVersion + "/insights/" + name
+func insightsEndpoint(host, name string) string {
+    return host + apiVersion + "/insights/" + name
 }
 
 // NSSResponse response of Net Sentiment Score
@@ -34,13 +32,26 @@ type NSSResponse struct {
 
 // GetNSS get Net Sentiment Score of all time
 func (p *coreV1) GetNSS() (resp *NSSResponse, err error) {
-    return p.GetNSSByRange(time.Time{}, time.Time{})
+    return p.GetNSSContext(context.Background())
+}
+
+// GetNSSContext get Net Sentiment Score of all time bound to the given context
+func (p *coreV1) GetNSSContext(ctx context.Context) (resp *NSSResponse, err error) {
+    return p.GetNSSByRangeContext(ctx, time.Time{}, time.Time{})
 }
 
 // GetNSSByRange get Net Sentiment Score (NSS) in between the given start and end time
 func (p *coreV1) GetNSSByRange(start, end time.Time) (resp *NSSResponse, err error) {
+    return p.GetNSSByRangeContext(context.Background(), start, end)
+}
+
+// GetNSSByRangeContext get Net Sentiment Score (NSS) in between the given start and end time
+// bound to the given context
+func (p *coreV1) GetNSSByRangeContext(ctx context.Context, start, end time.Time) (resp *NSSResponse, err error) {
     var req *http.Request
-    req, err = http.NewRequest(http.MethodGet, insightsEndpoint(p.client.Config(), "nss"), nil)
+    if req, err = newRequest(ctx, OperationGetNSS, http.MethodGet, insightsEndpoint(p.client.Host(), "nss"), nil); err != nil {
+        return
+    }
     if !start.IsZero() && !end.IsZero() {
         if end.Before(start) {
             return nil, ErrorInvalidDateRange
@@ -50,16 +61,14 @@ func (p *coreV1) GetNSSByRange(start, end time.Time) (resp *NSSResponse, err err
         query.Set("end", strconv.FormatInt(end.UnixNano(), 10))
         req.URL.RawQuery = query.Encode()
     } else if start.IsZero() != end.IsZero() {
-        return nil, stackAfter(ErrorInvalidDateRange, "start and end time both must be given")
+        return nil, ErrorInvalidDateRange.WithDetail("start and end time both must be given")
     }
-    var httpResp *http.Response
-    if httpResp, err = p.client.Do(req); err == nil {
-        if httpResp.ContentLength > 0 {
-            resp = &NSSResponse{}
-            err = json.NewDecoder(httpResp.Body).Decode(resp)
-        } else {
-            resp = &NSSResponse{Status: &Status{Code: 0, Message: "OK"}}
-        }
+    resp = &NSSResponse{}
+    var decoded bool
+    if decoded, err = p.do(req, resp); err != nil {
+        resp = nil
+    } else if !decoded {
+        resp = &NSSResponse{Status: &Status{Code: 0, Message: "OK"}}
     }
     return
 }
@@ -13,16 +13,17 @@ limitations under the License.
 package v1
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
-	"encoding/json"
-	"bytes"
+	"time"
 
 	"github.com/aimmatic/aimmatic-go-sdk-placenext/rest"
 )
 
 // insightsEndpoint return endpoint path based on the given name
-func placeNextIngestEndpoint(config rest.Config, name string) string {
-	return config.GetPlaceNextHost() + apiVersion + "/placeNextIngest/" + name
+func placeNextIngestEndpoint(host, name string) string {
+	return host + apiVersion + "/placeNextIngest/" + name
 }
 
 // Geometry a generic standard GeoJSON geometry
@@ -47,24 +48,30 @@ func NewPolygonGeometry(coordinate [][][]float64) *Geometry {
 	return &Geometry{Type: "Polygon", Coordinates: coordinate}
 }
 
-// GetNSS send geometry in GeoJSON format to api server
+// GeometryImport send geometry in GeoJSON format to api server. The request carry a new
+// idempotency key, use GeometryImportContext with rest.WithIdempotencyKey to supply your own.
 func (p *coreV1) GeometryImport(geometryCollection *GeometryCollection) (resp *Response, err error) {
+	return p.GeometryImportContext(context.Background(), geometryCollection)
+}
+
+// GeometryImportContext send geometry in GeoJSON format to api server bound to the given context.
+// The body is encoded while it is sent and never buffered in memory.
+func (p *coreV1) GeometryImportContext(ctx context.Context, geometryCollection *GeometryCollection) (resp *Response, err error) {
 	var req *http.Request
-	buf := bytes.NewBuffer(nil)
-	if err = json.NewEncoder(buf).Encode(geometryCollection); err == nil {
-		req, err = http.NewRequest(http.MethodPost, placeNextIngestEndpoint(p.client.Config(), "GeometryImport"), buf)
-		req.Header.Set(rest.ContentType, rest.MediaGeoJson)
-		var httpResp *http.Response
-		if httpResp, err = p.client.Do(req); err == nil {
-			if httpResp.ContentLength > 0 {
-				resp = &Response{}
-				err = json.NewDecoder(httpResp.Body).Decode(resp)
-			} else {
-				resp = &Response{Status: &Status{Code: 0, Message: "OK"}}
-			}
-		}
+	ctx = rest.EnsureIdempotencyKey(ctx)
+	if req, err = newStreamingRequest(ctx, p.client.SignatureScheme(), OperationGeometryImport, http.MethodPost, placeNextIngestEndpoint(p.client.Host(), "GeometryImport"), geometryCollection); err != nil {
+		return
 	}
-	return
+	req.Header.Set(rest.ContentType, rest.MediaGeoJson)
+	return p.doIngest(req)
+}
+
+// PresignGeometryImport return a presigned url a third party, such as a browser, can POST
+// geometry in GeoJSON format to until it expires without knowing the secret key. The url
+// is bound to the GeoJSON content type, use rest.WithPresignBodyDigest to also bind it to a body.
+func (p *coreV1) PresignGeometryImport(ctx context.Context, expires time.Duration, opts ...rest.PresignOption) (string, error) {
+	opts = append([]rest.PresignOption{rest.WithPresignContentType(rest.MediaGeoJson)}, opts...)
+	return p.client.Presign(ctx, http.MethodPost, placeNextIngestEndpoint(p.client.Host(), "GeometryImport"), expires, opts...)
 }
 
 // PointJSON point data
@@ -80,21 +87,36 @@ type PointJSON struct {
 	Longitude            float64    `json:"longitude"`
 }
 
-// PointImport send a batch LocationMeasurement to the placenext server
+// PointImport send a batch LocationMeasurement to the placenext server. The request carry a new
+// idempotency key, use PointImportContext with rest.WithIdempotencyKey to supply your own.
 func (p *coreV1) PointImport(lms []*PointJSON) (resp *Response, err error) {
+	return p.PointImportContext(context.Background(), lms)
+}
+
+// PointImportContext send a batch LocationMeasurement to the placenext server bound to the given context.
+// The body is encoded while it is sent and never buffered in memory.
+func (p *coreV1) PointImportContext(ctx context.Context, lms []*PointJSON) (resp *Response, err error) {
 	var req *http.Request
-	var buf []byte
-	if buf, err = json.Marshal(lms); err == nil {
-		req, err = http.NewRequest(http.MethodPost, placeNextIngestEndpoint(p.client.Config(), "PointImport"), bytes.NewReader(buf))
-		var httpResp *http.Response
-		if httpResp, err = p.client.Do(req); err == nil {
-			if httpResp.ContentLength > 0 {
-				resp = &Response{}
-				err = json.NewDecoder(httpResp.Body).Decode(resp)
-			} else {
-				resp = &Response{Status: &Status{Code: 0, Message: "OK"}}
-			}
+	ctx = rest.EnsureIdempotencyKey(ctx)
+	if req, err = newStreamingRequest(ctx, p.client.SignatureScheme(), OperationPointImport, http.MethodPost, placeNextIngestEndpoint(p.client.Host(), "PointImport"), lms); err != nil {
+		return
+	}
+	if resp, err = p.doIngest(req); err == nil {
+		if meter := p.client.Meter(); meter != nil {
+			meter.Counter(rest.MetricPointsIngested).Add(ctx, int64(len(lms)), slog.String("operation", OperationPointImport))
 		}
 	}
 	return
 }
+
+// doIngest send an ingest request and decode its response
+func (p *coreV1) doIngest(req *http.Request) (resp *Response, err error) {
+	resp = &Response{}
+	var decoded bool
+	if decoded, err = p.do(req, resp); err != nil {
+		resp = nil
+	} else if !decoded {
+		resp = &Response{Status: &Status{Code: 0, Message: "OK"}}
+	}
+	return
+}
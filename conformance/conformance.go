@@ -0,0 +1,381 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package conformance provides versioned JSON test vectors of the AimMatic request
+// signature computed by rest.ComputeSignature. Services that sign PlaceNext requests
+// in other languages check their implementation against the published vectors in
+// vectors/v1.json, the Go SDK check it still produce them.
+package conformance
+
+import (
+    "bytes"
+    _ "embed"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+
+    "github.com/aimmatic/aimmatic-go-sdk-placenext/rest"
+)
+
+// Version is the version of the vectors generated by this package. It change whenever
+// the expectation of a vector change, vectors can be added within a version.
+const Version = 1
+
+// ErrUnsupportedVersion is returned when loading vectors of another version
+var ErrUnsupportedVersion = errors.New("conformance vectors version is not supported")
+
+// ErrMismatch is returned when a vector does not match the signature of rest.ComputeSignature
+var ErrMismatch = errors.New("conformance vector does not match")
+
+// Suite is a versioned set of vectors
+type Suite struct {
+    Version int      `json:"version"`
+    Scheme  string   `json:"scheme"`
+    Vectors []Vector `json:"vectors"`
+}
+
+// Vector is a request and the expected result of signing it
+type Vector struct {
+    Name        string `json:"name"`
+    Description string `json:"description"`
+    Method      string `json:"method"`
+    // URL is absolute for a request signed by a client or a path for a request received
+    // by a server, which url is rebuilt from Host and X-Forwarded-Proto
+    URL     string              `json:"url"`
+    Host    string              `json:"host,omitempty"`
+    Headers map[string][]string `json:"headers"`
+    Body    string              `json:"body"`
+    // EmptyBody send Body even if it is empty, as a request with an empty reader
+    EmptyBody bool `json:"emptyBody,omitempty"`
+    // Secret is the base64 secret key without padding as found in a config
+    Secret string `json:"secret"`
+    // ContentMD5 is the expected Content-MD5 header, empty without body
+    ContentMD5 string `json:"contentMd5"`
+    // CanonicalString is the expected string to sign
+    CanonicalString string `json:"canonicalString"`
+    // Signature is the expected base64 signature without padding
+    Signature string `json:"signature"`
+}
+
+// Request build the http request of the vector
+func (v *Vector) Request() (*http.Request, error) {
+    var body io.Reader
+    if v.Body != "" || v.EmptyBody {
+        body = strings.NewReader(v.Body)
+    }
+    req, err := http.NewRequest(v.Method, v.URL, body)
+    if err != nil {
+        return nil, err
+    }
+    if v.Host != "" {
+        req.Host = v.Host
+    }
+    for k, values := range v.Headers {
+        for _, value := range values {
+            req.Header.Add(k, value)
+        }
+    }
+    return req, nil
+}
+
+// sign compute the canonical string and the signature of the vector request
+func (v *Vector) sign() (contentMD5, canonicalString, signature string, err error) {
+    req, err := v.Request()
+    if err != nil {
+        return
+    }
+    secret, err := rest.GetSecretKeyAsByte(v.Secret)
+    if err != nil {
+        return
+    }
+    stringToSign, _, contentMD5, err := rest.StringToSign(req)
+    if err != nil {
+        return
+    }
+    // the body was read by StringToSign
+    if req, err = v.Request(); err != nil {
+        return
+    }
+    _, _, signature, _, err = rest.ComputeSignature(req, secret)
+    return contentMD5, string(stringToSign), signature, err
+}
+
+// Check compare the vector with the signature computed by rest.ComputeSignature. The
+// error describe the component of the canonical string that differ.
+func (v *Vector) Check() error {
+    contentMD5, canonicalString, signature, err := v.sign()
+    if err != nil {
+        return fmt.Errorf("%s: %w", v.Name, err)
+    }
+    if canonicalString != v.CanonicalString {
+        detail := fmt.Sprintf("canonical string %q", canonicalString)
+        expected, expectedErr := rest.ParseCanonicalRequest([]byte(v.CanonicalString))
+        actual, actualErr := rest.ParseCanonicalRequest([]byte(canonicalString))
+        if expectedErr == nil && actualErr == nil {
+            var diffs []string
+            for _, diff := range rest.CompareCanonical(expected, actual) {
+                diffs = append(diffs, diff.String())
+            }
+            detail = strings.Join(diffs, ", ")
+        }
+        return fmt.Errorf("%w: %s: %s", ErrMismatch, v.Name, detail)
+    }
+    if contentMD5 != v.ContentMD5 {
+        return fmt.Errorf("%w: %s: content md5: expected %q got %q", ErrMismatch, v.Name, v.ContentMD5, contentMD5)
+    }
+    if signature != v.Signature {
+        return fmt.Errorf("%w: %s: signature: expected %q got %q", ErrMismatch, v.Name, v.Signature, signature)
+    }
+    return nil
+}
+
+// Check compare every vector of the suite, see Vector.Check
+func (s *Suite) Check() error {
+    if s.Version != Version {
+        return fmt.Errorf("%w: %d", ErrUnsupportedVersion, s.Version)
+    }
+    var errs []error
+    for i := range s.Vectors {
+        if err := s.Vectors[i].Check(); err != nil {
+            errs = append(errs, err)
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// WriteTo write the suite as indented json
+func (s *Suite) WriteTo(w io.Writer) (int64, error) {
+    buf := bytes.NewBuffer(nil)
+    encoder := json.NewEncoder(buf)
+    encoder.SetEscapeHTML(false)
+    encoder.SetIndent("", "  ")
+    if err := encoder.Encode(s); err != nil {
+        return 0, err
+    }
+    return buf.WriteTo(w)
+}
+
+// Load read a suite of the supported version
+func Load(r io.Reader) (*Suite, error) {
+    suite := &Suite{}
+    if err := json.NewDecoder(r).Decode(suite); err != nil {
+        return nil, err
+    }
+    if suite.Version != Version {
+        return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, suite.Version)
+    }
+    return suite, nil
+}
+
+//go:embed vectors/v1.json
+var published []byte
+
+// Published return the vectors published in vectors/v1.json
+func Published() (*Suite, error) {
+    return Load(bytes.NewReader(published))
+}
+
+// Generate compute the expectations of every case with rest.ComputeSignature
+func Generate() (*Suite, error) {
+    suite := &Suite{Version: Version, Scheme: rest.SignatureV1.String()}
+    for _, v := range Cases() {
+        var err error
+        if v.ContentMD5, v.CanonicalString, v.Signature, err = v.sign(); err != nil {
+            return nil, fmt.Errorf("%s: %w", v.Name, err)
+        }
+        suite.Vectors = append(suite.Vectors, v)
+    }
+    return suite, nil
+}
+
+// secret of the vectors, it is not a real secret key
+const secret = "dMAMNw6HE60xDhV0SWZNsVZSVW91culvEXBFLE76ij62wsZXXqI+aQ"
+
+// date of the vectors
+const date = "Mon, 02 Jan 2006 15:04:05 UTC"
+
+// Cases return the requests of the vectors without their expectations
+func Cases() []Vector {
+    cases := []Vector{
+        {
+            Name:        "get-without-body",
+            Description: "a request without body has no content md5 line",
+            Method:      http.MethodGet,
+            URL:         "https://api.aimmatic.com/v1/insights/nss",
+            Headers:     map[string][]string{"X-PlaceNext-Date": {date}},
+        },
+        {
+            Name:        "post-json-body",
+            Description: "the body md5 and the content type come before the date",
+            Method:      http.MethodPost,
+            URL:         "https://api.aimmatic.com/v1/placeNextIngest/PointImport",
+            Headers: map[string][]string{
+                "Content-Type":     {rest.MediaJson},
+                "X-PlaceNext-Date": {date},
+            },
+            Body: `{"points":[{"lat":13.7563,"lng":100.5018}]}`,
+        },
+        {
+            Name:        "post-empty-body",
+            Description: "an empty body has no content md5 line",
+            Method:      http.MethodPost,
+            URL:         "https://api.aimmatic.com/v1/placeNextIngest/PointImport",
+            Headers: map[string][]string{
+                "Content-Type":     {rest.MediaJson},
+                "X-PlaceNext-Date": {date},
+            },
+        },
+        {
+            Name:        "post-explicit-empty-body",
+            Description: "an empty body reader has no content md5 line",
+            Method:      http.MethodPost,
+            URL:         "https://api.aimmatic.com/v1/placeNextIngest/PointImport",
+            Headers: map[string][]string{
+                "Content-Type":     {rest.MediaJson},
+                "X-PlaceNext-Date": {date},
+            },
+            EmptyBody: true,
+        },
+        {
+            Name:        "content-type-without-body",
+            Description: "the content type is signed even without body",
+            Method:      http.MethodDelete,
+            URL:         "https://api.aimmatic.com/v1/placeNextIngest/Geometry",
+            Headers: map[string][]string{
+                "Content-Type":     {rest.MediaGeoJson},
+                "X-PlaceNext-Date": {date},
+            },
+        },
+        {
+            Name:        "date-header",
+            Description: "the Date header is signed when X-PlaceNext-Date is missing",
+            Method:      http.MethodGet,
+            URL:         "https://api.aimmatic.com/v1/insights/nss",
+            Headers:     map[string][]string{"Date": {date}},
+        },
+        {
+            Name:        "multi-valued-header",
+            Description: "the values of a X-Placenext-* header are sorted and joined with a comma without space",
+            Method:      http.MethodPost,
+            URL:         "https://api.aimmatic.com/v1/placeNextIngest/GeometryImport",
+            Headers: map[string][]string{
+                "Content-Type":     {rest.MediaGeoJson},
+                "X-PlaceNext-Date": {date},
+                "X-PlaceNext-Tag":  {"zulu", "alpha", "mike"},
+            },
+            Body: `{"type":"GeometryCollection","geometries":[]}`,
+        },
+        {
+            Name:        "sorted-headers",
+            Description: "the X-Placenext-* header names are lower cased, sorted and concatenated without separator, other header are not signed",
+            Method:      http.MethodPost,
+            URL:         "https://api.aimmatic.com/v1/placeNextIngest/PointImport",
+            Headers: map[string][]string{
+                "Content-Type":                {rest.MediaJson},
+                "User-Agent":                  {"aimmatic 1.0"},
+                "X-Request-Id":                {"0f9c"},
+                "X-PlaceNext-Date":            {date},
+                "X-PlaceNext-Idempotency-Key": {"6d3c5a0e-6f0a-4b8e-9d6e-3f1b2c4d5e6f"},
+                "X-Placenext-Client":          {"placenext-go"},
+            },
+            Body: `[]`,
+        },
+        {
+            Name:        "prefix-header-names",
+            Description: "the header are sorted by name so a name that is the prefix of another come first, even though - and digits sort before :",
+            Method:      http.MethodGet,
+            URL:         "https://api.aimmatic.com/v1/insights/nss",
+            Headers: map[string][]string{
+                "X-PlaceNext-Date":       {date},
+                "X-PlaceNext-Date-Local": {"2006-01-02T22:04:05+07:00"},
+                "X-Placenext-Tag":        {"one"},
+                "X-Placenext-Tag2":       {"two"},
+            },
+        },
+        {
+            Name:        "multi-valued-header-with-prefix",
+            Description: "a multi valued header follow the single valued header which name is its prefix",
+            Method:      http.MethodPost,
+            URL:         "https://api.aimmatic.com/v1/placeNextIngest/GeometryImport",
+            Headers: map[string][]string{
+                "Content-Type":        {rest.MediaGeoJson},
+                "X-PlaceNext-Date":    {date},
+                "X-Placenext-Tag":     {"solo"},
+                "X-Placenext-Tag-Set": {"zulu", "alpha", "mike"},
+            },
+            Body: `{"type":"GeometryCollection","geometries":[]}`,
+        },
+        {
+            Name:        "query-string",
+            Description: "the url of a client request is signed as is, the query is not reordered",
+            Method:      http.MethodGet,
+            URL:         "https://api.aimmatic.com/v1/insights/nss?start=2006-01-02&end=2006-01-01",
+            Headers:     map[string][]string{"X-PlaceNext-Date": {date}},
+        },
+        {
+            Name:        "client-trailing-slash",
+            Description: "the url of a client request keep its trailing slash",
+            Method:      http.MethodGet,
+            URL:         "https://api.aimmatic.com/v1/insights/nss/",
+            Headers:     map[string][]string{"X-PlaceNext-Date": {date}},
+        },
+        {
+            Name:        "server-trailing-slash",
+            Description: "the url of a server request is rebuilt from the host and lose its trailing slash",
+            Method:      http.MethodGet,
+            URL:         "/v1/insights/nss/",
+            Host:        "api.aimmatic.com",
+            Headers:     map[string][]string{"X-PlaceNext-Date": {date}},
+        },
+        {
+            Name:        "server-trailing-slash-with-query",
+            Description: "only a trailing slash at the end of the url is removed, not one before the query",
+            Method:      http.MethodGet,
+            URL:         "/v1/insights/nss/?start=2006-01-02",
+            Host:        "api.aimmatic.com",
+            Headers:     map[string][]string{"X-PlaceNext-Date": {date}},
+        },
+        {
+            Name:        "server-forwarded-proto",
+            Description: "the scheme of a server request is X-Forwarded-Proto, otherwise http without tls",
+            Method:      http.MethodPost,
+            URL:         "/v1/placeNextIngest/PointImport?dryRun=true",
+            Host:        "api.aimmatic.com:8443",
+            Headers: map[string][]string{
+                "Content-Type":      {rest.MediaJson},
+                "X-Forwarded-Proto": {"https"},
+                "X-PlaceNext-Date":  {date},
+            },
+            Body: `{"points":[]}`,
+        },
+        {
+            Name:        "server-without-forwarded-proto",
+            Description: "the scheme of a server request without tls nor X-Forwarded-Proto is http",
+            Method:      http.MethodPost,
+            URL:         "/v1/placeNextIngest/PointImport",
+            Host:        "localhost:8080",
+            Headers: map[string][]string{
+                "Content-Type":     {rest.MediaJson},
+                "X-PlaceNext-Date": {date},
+            },
+            Body: `{"points":[]}`,
+        },
+    }
+    for i := range cases {
+        cases[i].Secret = secret
+    }
+    return cases
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conformance
+
+import (
+    "testing"
+    "bytes"
+    "errors"
+    "flag"
+    "os"
+    "strings"
+)
+
+var update = flag.Bool("update", false, "regenerate vectors/v1.json")
+
+func TestPublishedVectors(t *testing.T) {
+    generated, err := Generate()
+    if err != nil {
+        t.Fatal(err)
+    }
+    var buf bytes.Buffer
+    if _, err = generated.WriteTo(&buf); err != nil {
+        t.Fatal(err)
+    }
+    if *update {
+        if err = os.WriteFile("vectors/v1.json", buf.Bytes(), 0644); err != nil {
+            t.Fatal(err)
+        }
+        return
+    }
+    // a change of the signature must bump Version and regenerate the vectors
+    if !bytes.Equal(buf.Bytes(), published) {
+        t.Fatal("expect vectors/v1.json to be up to date, run go test with -update and bump Version if an expectation changed")
+    }
+    suite, err := Published()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err = suite.Check(); err != nil {
+        t.Error(err)
+    }
+    if len(suite.Vectors) != len(Cases()) {
+        t.Error("expect a vector per case got", len(suite.Vectors))
+    }
+}
+
+// baseline is the canonical string and signature of the vectors most likely to drift as
+// computed by the original implementation of ComputeSignature, independently of Generate
+var baseline = map[string][2]string{
+    "prefix-header-names": {
+        "Mon, 02 Jan 2006 15:04:05 UTC\n" +
+            "x-placenext-date:Mon, 02 Jan 2006 15:04:05 UTCx-placenext-date-local:2006-01-02T22:04:05+07:00x-placenext-tag:onex-placenext-tag2:two\n" +
+            "https://api.aimmatic.com/v1/insights/nss",
+        "V3nwEdA3JuCRjcUaqIkY5FSudJxZIP55ZQuruxNg6xY",
+    },
+    "multi-valued-header-with-prefix": {
+        "Xwe9o6s+FjNmXeomNu0J6A\napplication/geo+json\nMon, 02 Jan 2006 15:04:05 UTC\n" +
+            "x-placenext-date:Mon, 02 Jan 2006 15:04:05 UTCx-placenext-tag:solox-placenext-tag-set:alpha,mike,zulu\n" +
+            "https://api.aimmatic.com/v1/placeNextIngest/GeometryImport",
+        "h3F4vcYm+eXUFkQqeKEab77aopm4op5/frPKMM2MFNg",
+    },
+    "post-explicit-empty-body": {
+        "application/json; charset=utf-8\nMon, 02 Jan 2006 15:04:05 UTC\n" +
+            "x-placenext-date:Mon, 02 Jan 2006 15:04:05 UTC\n" +
+            "https://api.aimmatic.com/v1/placeNextIngest/PointImport",
+        "1T4AATXUILq1DCiRYgl0nv81B8mn01Fg2RNC2sYemlI",
+    },
+}
+
+func TestBaselineVectors(t *testing.T) {
+    suite, err := Published()
+    if err != nil {
+        t.Fatal(err)
+    }
+    found := 0
+    for _, v := range suite.Vectors {
+        expect, ok := baseline[v.Name]
+        if !ok {
+            continue
+        }
+        found++
+        if v.CanonicalString != expect[0] {
+            t.Errorf("%s: expect canonical string\n%s\ngot\n%s", v.Name, expect[0], v.CanonicalString)
+        }
+        if v.Signature != expect[1] {
+            t.Errorf("%s: expect signature %s got %s", v.Name, expect[1], v.Signature)
+        }
+    }
+    if found != len(baseline) {
+        t.Error("expect every baseline vector to be published got", found)
+    }
+}
+
+func TestVectorMismatch(t *testing.T) {
+    suite, err := Published()
+    if err != nil {
+        t.Fatal(err)
+    }
+    v := suite.Vectors[0]
+    v.URL += "/"
+    err = v.Check()
+    if !errors.Is(err, ErrMismatch) || !strings.Contains(err.Error(), "url: expected") {
+        t.Error("expect url mismatch got", err)
+    }
+    v = suite.Vectors[0]
+    v.Signature = "AAAA"
+    if err = v.Check(); !errors.Is(err, ErrMismatch) {
+        t.Error("expect signature mismatch got", err)
+    }
+    if _, err = Load(strings.NewReader(`{"version":2}`)); !errors.Is(err, ErrUnsupportedVersion) {
+        t.Error("expect unsupported version got", err)
+    }
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "context"
+    "crypto/md5"
+    "crypto/sha256"
+    "hash"
+    "io"
+    "net/http"
+    "sync"
+)
+
+// BodySource write the request body to w. A source is called once to compute
+// the body digest and then once for every attempt to send the body, therefore
+// it must produce the same bytes each time.
+type BodySource func(w io.Writer) error
+
+// digestBody is a request body which digests are known before it is read
+type digestBody struct {
+    io.ReadCloser
+    md5    []byte
+    sha256 []byte
+}
+
+// ContentMD5 return the md5 digest of the body or nil if it is not known
+func (b *digestBody) ContentMD5() []byte {
+    return b.md5
+}
+
+// ContentSHA256 return the sha-256 digest of the body or nil if it is not known
+func (b *digestBody) ContentSHA256() []byte {
+    return b.sha256
+}
+
+// contentMD5er is implemented by a request body which digest is precomputed
+type contentMD5er interface {
+    ContentMD5() []byte
+}
+
+// contentSHA256er is implemented by a request body which sha-256 digest is precomputed
+type contentSHA256er interface {
+    ContentSHA256() []byte
+}
+
+// streamDigest hold the digests of a streamed body. The digest of the signature scheme
+// is computed with the length, the other one is only computed if it is needed so a body
+// signed with SignatureV2 never use md5.
+type streamDigest struct {
+    source     BodySource
+    md5Once    sync.Once
+    md5        []byte
+    sha256Once sync.Once
+    sha256     []byte
+}
+
+// contentMD5 return the md5 digest, the source is run if it was not computed
+func (d *streamDigest) contentMD5() []byte {
+    d.md5Once.Do(func() {
+        if d.md5 == nil {
+            d.md5 = d.sum(md5.New())
+        }
+    })
+    return d.md5
+}
+
+// contentSHA256 return the sha-256 digest, the source is run if it was not computed
+func (d *streamDigest) contentSHA256() []byte {
+    d.sha256Once.Do(func() {
+        if d.sha256 == nil {
+            d.sha256 = d.sum(sha256.New())
+        }
+    })
+    return d.sha256
+}
+
+// sum run the source through the hash and return the digest or nil if the source fail
+func (d *streamDigest) sum(h hash.Hash) []byte {
+    if d.source(h) != nil {
+        return nil
+    }
+    return h.Sum(nil)
+}
+
+// streamBody run its source on the first read and stream the output through a pipe
+type streamBody struct {
+    source BodySource
+    digest *streamDigest
+    once   sync.Once
+    reader *io.PipeReader
+}
+
+func (b *streamBody) start() {
+    var writer *io.PipeWriter
+    b.reader, writer = io.Pipe()
+    go func() {
+        writer.CloseWithError(b.source(writer))
+    }()
+}
+
+func (b *streamBody) Read(p []byte) (int, error) {
+    b.once.Do(b.start)
+    return b.reader.Read(p)
+}
+
+// Close stop the source if it is running
+func (b *streamBody) Close() error {
+    b.once.Do(func() {})
+    if b.reader != nil {
+        return b.reader.Close()
+    }
+    return nil
+}
+
+// ContentMD5 return the md5 digest of the body
+func (b *streamBody) ContentMD5() []byte {
+    return b.digest.contentMD5()
+}
+
+// ContentSHA256 return the sha-256 digest of the body
+func (b *streamBody) ContentSHA256() []byte {
+    return b.digest.contentSHA256()
+}
+
+// countWriter count the bytes written through it
+type countWriter struct {
+    n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+    w.n += int64(len(p))
+    return len(p), nil
+}
+
+// NewStreamingRequest create a request which body is produced by the given source.
+// The source is run a first time to compute the body length and the digest signed by
+// the scheme, md5 for SignatureV1 and sha-256 for SignatureV2, then the body is streamed
+// for each attempt so the body is never held in memory. Signing with the other scheme
+// cost one more run of the source.
+func NewStreamingRequest(ctx context.Context, method, url string, scheme SignatureScheme, source BodySource) (*http.Request, error) {
+    h := md5.New()
+    if scheme == SignatureV2 {
+        h = sha256.New()
+    }
+    counter := &countWriter{}
+    if err := source(io.MultiWriter(h, counter)); err != nil {
+        return nil, err
+    }
+    req, err := http.NewRequestWithContext(ctx, method, url, nil)
+    if err != nil {
+        return nil, err
+    }
+    if counter.n == 0 {
+        return req, nil
+    }
+    digest := &streamDigest{source: source}
+    if scheme == SignatureV2 {
+        digest.sha256 = h.Sum(nil)
+    } else {
+        digest.md5 = h.Sum(nil)
+    }
+    req.ContentLength = counter.n
+    req.GetBody = func() (io.ReadCloser, error) {
+        return &streamBody{source: source, digest: digest}, nil
+    }
+    req.Body, _ = req.GetBody()
+    return req, nil
+}
+
+// SetBodyDigest record the md5 digest of the request body computed by the caller so
+// the body is not read to compute the signature. The digest must match the body.
+func SetBodyDigest(req *http.Request, md5sum []byte) {
+    setDigest(req, func(b *digestBody) { b.md5 = md5sum })
+}
+
+// SetContentDigest record the sha-256 digest of the request body computed by the caller
+// so the body is not read to compute the SignatureV2 signature. The digest must match the body.
+func SetContentDigest(req *http.Request, sha256sum []byte) {
+    setDigest(req, func(b *digestBody) { b.sha256 = sha256sum })
+}
+
+// setDigest wrap the request body so set record a digest of it
+func setDigest(req *http.Request, set func(b *digestBody)) {
+    if req.Body == nil || req.Body == http.NoBody {
+        return
+    }
+    wrap := func(body io.ReadCloser) io.ReadCloser {
+        digest, ok := body.(*digestBody)
+        if !ok {
+            digest = &digestBody{ReadCloser: body}
+        }
+        set(digest)
+        return digest
+    }
+    req.Body = wrap(req.Body)
+    if getBody := req.GetBody; getBody != nil {
+        req.GetBody = func() (io.ReadCloser, error) {
+            body, err := getBody()
+            if err != nil {
+                return nil, err
+            }
+            return wrap(body), nil
+        }
+    }
+}
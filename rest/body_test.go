@@ -0,0 +1,87 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "context"
+    "net/http"
+    "io"
+    "io/ioutil"
+    "bytes"
+    "crypto/md5"
+)
+
+// errReader fail the test if the body is read
+type errReader struct {
+    t *testing.T
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+    r.t.Error("body must not be read to compute the signature")
+    return 0, io.EOF
+}
+
+func TestStreamingSignature(t *testing.T) {
+    config, _ := NewConfig(apiKey, secretKey)
+    newRequest := func(body io.Reader) *http.Request {
+        req, _ := http.NewRequest("POST", "http://api.aimmatic.com", body)
+        return req
+    }
+    sign := func(req *http.Request) string {
+        req.Header.Set(Date, date)
+        req.Header.Set(XPlacenextDate, date)
+        req.Header.Set(ContentType, MediaJson)
+        _, _, signB64, _, err := ComputeSignature(req, config.GetSecretKey())
+        if err != nil {
+            t.Fatal(err)
+        }
+        return signB64
+    }
+    // body which cannot be replayed is buffered
+    req := newRequest(ioutil.NopCloser(bytes.NewBufferString(body)))
+    req.ContentLength = int64(len(body))
+    expect := sign(req)
+    // body which can be replayed
+    if signature := sign(newRequest(bytes.NewBufferString(body))); signature != expect {
+        t.Error("replayable body expect signature", expect, "got", signature)
+    }
+    // body produced by a source, its md5 is computed with its length
+    runs := 0
+    req, err := NewStreamingRequest(context.Background(), "POST", "http://api.aimmatic.com", SignatureV1, func(w io.Writer) error {
+        runs++
+        _, err := io.WriteString(w, body)
+        return err
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if signature := sign(req); signature != expect {
+        t.Error("streaming body expect signature", expect, "got", signature)
+    }
+    if runs != 1 {
+        t.Error("expect the source to run once to sign got", runs)
+    }
+    if b, _ := ioutil.ReadAll(req.Body); string(b) != body || req.ContentLength != int64(len(body)) {
+        t.Error("unexpected streaming body", string(b), req.ContentLength)
+    }
+    // body with a precomputed digest
+    req = newRequest(&errReader{t: t})
+    req.ContentLength = int64(len(body))
+    sum := md5.Sum([]byte(body))
+    SetBodyDigest(req, sum[:])
+    if signature := sign(req); signature != expect {
+        t.Error("precomputed digest expect signature", expect, "got", signature)
+    }
+}
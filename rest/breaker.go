@@ -0,0 +1,310 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "sync"
+    "time"
+)
+
+// ErrCircuitOpen is returned without sending the request when the circuit breaker of
+// its endpoint is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+const (
+    // BreakerClosed let every request through
+    BreakerClosed BreakerState = iota
+    // BreakerOpen fail every request fast until the cool-down elapse
+    BreakerOpen
+    // BreakerHalfOpen let a few probe requests through to decide whether to close again
+    BreakerHalfOpen
+)
+
+// String return the name of the state
+func (s BreakerState) String() string {
+    switch s {
+    case BreakerClosed:
+        return "closed"
+    case BreakerOpen:
+        return "open"
+    case BreakerHalfOpen:
+        return "half-open"
+    }
+    return "unknown"
+}
+
+// BreakerEvent is a state change of the circuit breaker of an endpoint
+type BreakerEvent struct {
+    Endpoint string
+    From     BreakerState
+    To       BreakerState
+    Time     time.Time
+}
+
+// BreakerSettings configure the circuit breakers. A zero field use its default.
+type BreakerSettings struct {
+    // Window is the period over which the failure ratio is measured, default 1 minute
+    Window time.Duration
+    // MinRequests is the number of requests within the window before the failure
+    // ratio can trip the breaker, default 10
+    MinRequests int
+    // FailureRatio trip the breaker when the ratio of failed requests within the
+    // window reach it, default 0.5
+    FailureRatio float64
+    // ConsecutiveFailures trip the breaker after that many failures in a row, default 5
+    ConsecutiveFailures int
+    // CoolDown is how long the breaker stay open before letting probes through, default 30 seconds
+    CoolDown time.Duration
+    // HalfOpenRequests is the number of probes let through while half-open, they must
+    // all succeed to close the breaker, default 1
+    HalfOpenRequests int
+    // IsFailure report whether the result of a request count as a failure. If nil, a
+    // transport error other than a cancellation or a 5xx status is a failure.
+    IsFailure func(resp *http.Response, err error) bool
+    // OnStateChange is called on every state change, for instance to alert
+    OnStateChange func(event BreakerEvent)
+    // Now return the current time, if nil time.Now is used
+    Now func() time.Time
+}
+
+// withDefaults return the settings with the defaults of the zero fields
+func (s BreakerSettings) withDefaults() BreakerSettings {
+    if s.Window <= 0 {
+        s.Window = time.Minute
+    }
+    if s.MinRequests <= 0 {
+        s.MinRequests = 10
+    }
+    if s.FailureRatio <= 0 {
+        s.FailureRatio = 0.5
+    }
+    if s.ConsecutiveFailures <= 0 {
+        s.ConsecutiveFailures = 5
+    }
+    if s.CoolDown <= 0 {
+        s.CoolDown = 30 * time.Second
+    }
+    if s.HalfOpenRequests <= 0 {
+        s.HalfOpenRequests = 1
+    }
+    if s.IsFailure == nil {
+        s.IsFailure = isBreakerFailure
+    }
+    if s.Now == nil {
+        s.Now = time.Now
+    }
+    return s
+}
+
+// isBreakerFailure is the default failure of a circuit breaker
+func isBreakerFailure(resp *http.Response, err error) bool {
+    if err != nil {
+        return !errors.Is(err, context.Canceled)
+    }
+    return resp.StatusCode >= 500
+}
+
+// Breaker is the circuit breaker of an endpoint
+type Breaker struct {
+    endpoint string
+    settings BreakerSettings
+
+    mu          sync.Mutex
+    state       BreakerState
+    generation  uint64
+    windowStart time.Time
+    requests    int
+    failures    int
+    consecutive int
+    openedAt    time.Time
+    probes      int
+    successes   int
+}
+
+// State return the current state of the breaker
+func (b *Breaker) State() BreakerState {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    now := b.settings.Now()
+    b.advance(now)
+    return b.state
+}
+
+// Allow report whether a request can be sent. If so, done must be called with the
+// result of the request, otherwise the error wrap ErrCircuitOpen.
+func (b *Breaker) Allow() (done func(resp *http.Response, err error), err error) {
+    b.mu.Lock()
+    now := b.settings.Now()
+    event := b.advance(now)
+    switch {
+    case b.state == BreakerOpen:
+        err = fmt.Errorf("%w: %s until %s", ErrCircuitOpen, b.endpoint, b.openedAt.Add(b.settings.CoolDown).Format(time.RFC3339))
+    case b.state == BreakerHalfOpen && b.probes >= b.settings.HalfOpenRequests:
+        err = fmt.Errorf("%w: %s is probing", ErrCircuitOpen, b.endpoint)
+    case b.state == BreakerHalfOpen:
+        b.probes++
+    }
+    generation := b.generation
+    b.mu.Unlock()
+    b.fire(event)
+    if err != nil {
+        return nil, err
+    }
+    return func(resp *http.Response, err error) {
+        b.done(generation, b.settings.IsFailure(resp, err))
+    }, nil
+}
+
+// done record the result of a request allowed in the given generation
+func (b *Breaker) done(generation uint64, failure bool) {
+    b.mu.Lock()
+    now := b.settings.Now()
+    event := b.advance(now)
+    // the result of a request sent before the last state change is ignored
+    if generation != b.generation {
+        b.mu.Unlock()
+        b.fire(event)
+        return
+    }
+    switch b.state {
+    case BreakerClosed:
+        b.requests++
+        if failure {
+            b.failures++
+            b.consecutive++
+        } else {
+            b.consecutive = 0
+        }
+        if b.consecutive >= b.settings.ConsecutiveFailures ||
+            (b.requests >= b.settings.MinRequests && float64(b.failures)/float64(b.requests) >= b.settings.FailureRatio) {
+            event = b.transition(BreakerOpen, now)
+        }
+    case BreakerHalfOpen:
+        if failure {
+            event = b.transition(BreakerOpen, now)
+        } else if b.successes++; b.successes >= b.settings.HalfOpenRequests {
+            event = b.transition(BreakerClosed, now)
+        }
+    }
+    b.mu.Unlock()
+    b.fire(event)
+}
+
+// advance move an open breaker to half-open once the cool-down elapsed and reset the
+// counts of a closed breaker at the end of the window, it return the state change if any
+func (b *Breaker) advance(now time.Time) *BreakerEvent {
+    switch b.state {
+    case BreakerOpen:
+        if !now.Before(b.openedAt.Add(b.settings.CoolDown)) {
+            return b.transition(BreakerHalfOpen, now)
+        }
+    case BreakerClosed:
+        if now.Sub(b.windowStart) >= b.settings.Window {
+            b.windowStart, b.requests, b.failures = now, 0, 0
+        }
+    }
+    return nil
+}
+
+// transition change the state and reset the counts
+func (b *Breaker) transition(state BreakerState, now time.Time) *BreakerEvent {
+    event := &BreakerEvent{Endpoint: b.endpoint, From: b.state, To: state, Time: now}
+    b.state = state
+    b.generation++
+    b.windowStart, b.requests, b.failures, b.consecutive = now, 0, 0, 0
+    b.probes, b.successes = 0, 0
+    if state == BreakerOpen {
+        b.openedAt = now
+    }
+    return event
+}
+
+// fire the state change event outside of the lock
+func (b *Breaker) fire(event *BreakerEvent) {
+    if event != nil && b.settings.OnStateChange != nil {
+        b.settings.OnStateChange(*event)
+    }
+}
+
+// Breakers hold a circuit breaker per endpoint
+type Breakers struct {
+    settings BreakerSettings
+
+    mu       sync.Mutex
+    breakers map[string]*Breaker
+}
+
+// NewBreakers create circuit breakers of the given settings
+func NewBreakers(settings BreakerSettings) *Breakers {
+    return &Breakers{settings: settings.withDefaults(), breakers: make(map[string]*Breaker)}
+}
+
+// For return the circuit breaker of the endpoint, see Endpoint
+func (bs *Breakers) For(endpoint string) *Breaker {
+    bs.mu.Lock()
+    defer bs.mu.Unlock()
+    b, ok := bs.breakers[endpoint]
+    if !ok {
+        b = &Breaker{endpoint: endpoint, settings: bs.settings, windowStart: bs.settings.Now()}
+        bs.breakers[endpoint] = b
+    }
+    return b
+}
+
+// WithBreakers guard every endpoint with a circuit breaker, see Client.SetBreakers
+func WithBreakers(breakers *Breakers) Option {
+    return func(o *clientOptions) {
+        o.breakers = breakers
+    }
+}
+
+// SetBreakers guard every endpoint of the client with a circuit breaker. Each attempt
+// of a request is checked and fail fast with ErrCircuitOpen while the breaker of its
+// endpoint is open. A nil breakers disable them.
+func (c *Client) SetBreakers(breakers *Breakers) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.breakers = breakers
+}
+
+// Breakers return the circuit breakers of the client or nil
+func (c *Client) Breakers() *Breakers {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.breakers
+}
+
+// breakerInterceptor is the built-in interceptor that check the circuit breaker of the
+// endpoint before sending an attempt and record its result
+func breakerInterceptor(breakers *Breakers) Interceptor {
+    return func(req *http.Request, next Exchange) (*http.Response, error) {
+        done, err := breakers.For(Endpoint(req)).Allow()
+        if err != nil {
+            return nil, err
+        }
+        resp, err := next(req)
+        done(resp, err)
+        return resp, err
+    }
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "time"
+)
+
+func TestClientBreakers(t *testing.T) {
+    status, calls := http.StatusServiceUnavailable, 0
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        calls++
+        w.WriteHeader(status)
+    }))
+    defer server.Close()
+    now := time.Now()
+    var events []BreakerEvent
+    breakers := NewBreakers(BreakerSettings{
+        ConsecutiveFailures: 3,
+        CoolDown:            time.Minute,
+        OnStateChange:       func(event BreakerEvent) { events = append(events, event) },
+        Now:                 func() time.Time { return now },
+    })
+    config, _ := NewConfig(apiKey, secretKey)
+    client := NewRestClient(config, WithBreakers(breakers))
+    do := func(path string) error {
+        req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+        resp, err := client.Do(req)
+        if err == nil {
+            resp.Body.Close()
+        }
+        return err
+    }
+    for i := 0; i < 3; i++ {
+        if err := do("/v1/insights/nss"); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := do("/v1/insights/nss"); !errors.Is(err, ErrCircuitOpen) || calls != 3 {
+        t.Error("expect open breaker to fail fast got", err, calls)
+    }
+    // other endpoints have their own breaker
+    if err := do("/v1/placeNextIngest/PointImport"); err != nil {
+        t.Error("expect closed breaker of another endpoint got", err)
+    }
+    // a failed probe open the breaker again
+    now = now.Add(time.Minute)
+    if err := do("/v1/insights/nss"); err != nil {
+        t.Fatal(err)
+    }
+    if state := breakers.For(server.URL + "/v1/insights/nss").State(); state != BreakerOpen {
+        t.Error("expect failed probe to open the breaker got", state)
+    }
+    // a successful probe close it
+    now, status = now.Add(time.Minute), http.StatusOK
+    if err := do("/v1/insights/nss"); err != nil {
+        t.Fatal(err)
+    }
+    var changes []string
+    for _, event := range events {
+        changes = append(changes, event.From.String()+">"+event.To.String())
+    }
+    expect := []string{"closed>open", "open>half-open", "half-open>open", "open>half-open", "half-open>closed"}
+    if len(changes) != len(expect) {
+        t.Fatal("wrong state changes", changes)
+    }
+    for i := range expect {
+        if changes[i] != expect[i] {
+            t.Error("wrong state changes", changes)
+            break
+        }
+    }
+}
+
+func TestBreakerFailureRatio(t *testing.T) {
+    b := NewBreakers(BreakerSettings{MinRequests: 4, FailureRatio: 0.5, ConsecutiveFailures: 100}).For("endpoint")
+    for i, failure := range []bool{true, false, true, false} {
+        done, err := b.Allow()
+        if err != nil {
+            t.Fatal("request", i, err)
+        }
+        if failure {
+            done(nil, errors.New("connection reset"))
+        } else {
+            done(&http.Response{StatusCode: http.StatusOK}, nil)
+        }
+    }
+    if _, err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+        t.Error("expect failure ratio to open the breaker got", err)
+    }
+}
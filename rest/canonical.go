@@ -0,0 +1,227 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "bytes"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "net/http"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+// ErrMalformedCanonical is returned when a string-to-sign cannot be parsed
+var ErrMalformedCanonical = errors.New("string to sign is malformed")
+
+// CanonicalRequest is the canonical form of a request signed by ComputeSignature
+type CanonicalRequest struct {
+    // ContentMD5 is the base64 md5 of the body, empty if the request has no body
+    ContentMD5 string
+    // ContentType is the Content-Type header, it can be empty
+    ContentType string
+    // Date is the X-PlaceNext-Date or Date header
+    Date string
+    // Headers are the X-Placenext-* header, one "name:value,value" per header sorted by name
+    Headers []string
+    // URL is the scheme, host, path and query of the request
+    URL string
+}
+
+// NewCanonicalRequest return the canonical form of the request, see StringToSign
+func NewCanonicalRequest(r *http.Request) (*CanonicalRequest, error) {
+    c, _, err := canonicalRequest(r)
+    return c, err
+}
+
+// canonicalRequest return the canonical form of the request with its body md5
+func canonicalRequest(r *http.Request) (c *CanonicalRequest, contentMD5 []byte, err error) {
+    c = &CanonicalRequest{ContentType: r.Header.Get(ContentType)}
+    if contentMD5 = ComputeBodyMd5Base64(r); contentMD5 != nil {
+        c.ContentMD5 = base64.RawStdEncoding.EncodeToString(contentMD5)
+    }
+    if c.Date = r.Header.Get(XPlacenextDate); c.Date == "" {
+        if c.Date = r.Header.Get(Date); c.Date == "" {
+            return nil, nil, ErrMissingDate
+        }
+    }
+    c.Headers = headerLines(r)
+    c.URL = signedURL(r)
+    return c, contentMD5, nil
+}
+
+// headerLines return a "name:value" line per X-Placenext-* header sorted by lower case
+// name, the values of a multi valued header are sorted and joined with a comma
+func headerLines(r *http.Request) []string {
+    names := make([]string, 0, 4)
+    for k, vs := range r.Header {
+        if strings.HasPrefix(k, "X-Placenext") && len(vs) > 0 {
+            names = append(names, k)
+        }
+    }
+    // sort by name before building the lines, a name that is the prefix of another
+    // come first while "-" and digits sort before ":"
+    sort.Slice(names, func(i, j int) bool { return strings.ToLower(names[i]) < strings.ToLower(names[j]) })
+    lines := make([]string, 0, len(names))
+    for _, k := range names {
+        vs := r.Header[k]
+        value := vs[0]
+        if len(vs) > 1 {
+            // sort a copy so the request header is not modified
+            values := append([]string(nil), vs...)
+            sort.Strings(values)
+            value = strings.Join(values, ",")
+        }
+        lines = append(lines, strings.ToLower(k)+":"+value)
+    }
+    return lines
+}
+
+// signedURL return the url signed by ComputeSignature. The url of a client request is
+// used as is while the url of a server request is rebuilt from its host and scheme.
+func signedURL(r *http.Request) string {
+    if r.URL.Scheme != "" {
+        return r.URL.String()
+    }
+    return requestScheme(r) + "://" + strings.TrimSuffix(r.Host+r.URL.String(), "/")
+}
+
+// Bytes return the string to sign
+func (c *CanonicalRequest) Bytes() []byte {
+    buf := bytes.NewBuffer(nil)
+    if c.ContentMD5 != "" {
+        buf.WriteString(c.ContentMD5)
+        buf.WriteByte('\n')
+    }
+    if c.ContentType != "" {
+        buf.WriteString(c.ContentType)
+        buf.WriteByte('\n')
+    }
+    buf.WriteString(c.Date)
+    buf.WriteByte('\n')
+    buf.WriteString(strings.Join(c.Headers, ""))
+    buf.WriteByte('\n')
+    buf.WriteString(c.URL)
+    return buf.Bytes()
+}
+
+// String return the string to sign
+func (c *CanonicalRequest) String() string {
+    return string(c.Bytes())
+}
+
+// headerName match the start of each header of the concatenated X-Placenext-* header
+var headerName = regexp.MustCompile(`x-placenext[a-z0-9-]*:`)
+
+// ParseCanonicalRequest parse a string to sign, for instance logged by the api server.
+// A single line before the date is the content md5 if it is a base64 md5, otherwise
+// it is the content type. A header value that contain "x-placenext" is split wrongly.
+func ParseCanonicalRequest(stringToSign []byte) (*CanonicalRequest, error) {
+    lines := strings.Split(string(stringToSign), "\n")
+    if len(lines) < 3 || len(lines) > 5 {
+        return nil, ErrMalformedCanonical
+    }
+    n := len(lines)
+    c := &CanonicalRequest{Date: lines[n-3], URL: lines[n-1]}
+    switch n {
+    case 5:
+        c.ContentMD5, c.ContentType = lines[0], lines[1]
+    case 4:
+        if isContentMD5(lines[0]) {
+            c.ContentMD5 = lines[0]
+        } else {
+            c.ContentType = lines[0]
+        }
+    }
+    headers := lines[n-2]
+    starts := headerName.FindAllStringIndex(headers, -1)
+    if headers != "" && (len(starts) == 0 || starts[0][0] != 0) {
+        return nil, ErrMalformedCanonical
+    }
+    for i, start := range starts {
+        end := len(headers)
+        if i+1 < len(starts) {
+            end = starts[i+1][0]
+        }
+        c.Headers = append(c.Headers, headers[start[0]:end])
+    }
+    return c, nil
+}
+
+// isContentMD5 report whether s is a raw base64 md5 digest
+func isContentMD5(s string) bool {
+    sum, err := base64.RawStdEncoding.DecodeString(s)
+    return err == nil && len(sum) == 16
+}
+
+// CanonicalDifference is a component that differ between two canonical requests
+type CanonicalDifference struct {
+    // Component is content-md5, content-type, date, url or the lower case name of an
+    // X-Placenext-* header
+    Component string
+    // Expected and Actual are the values of the component, empty if it is missing
+    Expected string
+    Actual   string
+}
+
+// String describe the difference
+func (d CanonicalDifference) String() string {
+    return fmt.Sprintf("%s: expected %q got %q", d.Component, d.Expected, d.Actual)
+}
+
+// CompareCanonical return the components that differ between the expected canonical
+// request, for instance the one of the api server, and the actual one signed by the
+// client. It return nil if they are equal.
+func CompareCanonical(expected, actual *CanonicalRequest) []CanonicalDifference {
+    var diffs []CanonicalDifference
+    compare := func(component, e, a string) {
+        if e != a {
+            diffs = append(diffs, CanonicalDifference{Component: component, Expected: e, Actual: a})
+        }
+    }
+    compare("content-md5", expected.ContentMD5, actual.ContentMD5)
+    compare("content-type", expected.ContentType, actual.ContentType)
+    compare("date", expected.Date, actual.Date)
+    e, a := headerValues(expected.Headers), headerValues(actual.Headers)
+    names := make([]string, 0, len(e)+len(a))
+    for name := range e {
+        names = append(names, name)
+    }
+    for name := range a {
+        if _, ok := e[name]; !ok {
+            names = append(names, name)
+        }
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        compare(name, e[name], a[name])
+    }
+    compare("url", expected.URL, actual.URL)
+    return diffs
+}
+
+// headerValues index the header lines by name
+func headerValues(lines []string) map[string]string {
+    values := make(map[string]string, len(lines))
+    for _, line := range lines {
+        name, value, _ := strings.Cut(line, ":")
+        values[name] = value
+    }
+    return values
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "strings"
+)
+
+func TestCanonicalRequest(t *testing.T) {
+    req, _ := http.NewRequest("POST", "https://api.aimmatic.com/v1/placeNextIngest/PointImport?b=2", strings.NewReader(body))
+    req.Header.Set(ContentType, MediaJson)
+    req.Header.Set(XPlacenextDate, "Mon, 02 Jan 2006 15:04:05 UTC")
+    req.Header.Add("X-Placenext-Tag", "b")
+    req.Header.Add("X-Placenext-Tag", "a")
+    client, err := NewCanonicalRequest(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    sts, _, _, _ := StringToSign(req)
+    if client.String() != string(sts) {
+        t.Errorf("expect canonical request to be the string to sign\n%s\ngot\n%s", sts, client)
+    }
+    expect := []string{"x-placenext-date:Mon, 02 Jan 2006 15:04:05 UTC", "x-placenext-tag:a,b"}
+    if !reflect.DeepEqual(client.Headers, expect) {
+        t.Error("expect headers", expect, "got", client.Headers)
+    }
+    // round trip through the string to sign
+    parsed, err := ParseCanonicalRequest(sts)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(parsed, client) {
+        t.Errorf("expect parsed canonical request %+v got %+v", client, parsed)
+    }
+    if diffs := CompareCanonical(client, parsed); diffs != nil {
+        t.Error("expect no difference got", diffs)
+    }
+    // a single line before the date is either the md5 or the content type
+    for _, c := range []*CanonicalRequest{
+        {ContentMD5: client.ContentMD5, Date: client.Date, URL: client.URL},
+        {ContentType: MediaGeoJson, Date: client.Date, URL: client.URL},
+    } {
+        if parsed, err = ParseCanonicalRequest(c.Bytes()); err != nil || !reflect.DeepEqual(parsed, c) {
+            t.Errorf("expect parsed canonical request %+v got %+v %v", c, parsed, err)
+        }
+    }
+    if _, err = ParseCanonicalRequest([]byte("url")); err != ErrMalformedCanonical {
+        t.Error("expect malformed string to sign got", err)
+    }
+    // the server behind a proxy see another url and a header added on the way
+    server := httptest.NewRequest("POST", "/v1/placeNextIngest/PointImport?b=2", strings.NewReader(body))
+    server.Host = "api.aimmatic.com"
+    server.Header = req.Header.Clone()
+    server.Header.Set("X-Placenext-Proxy", "1")
+    expected, err := NewCanonicalRequest(server)
+    if err != nil {
+        t.Fatal(err)
+    }
+    diffs := CompareCanonical(expected, client)
+    if len(diffs) != 2 || diffs[0].Component != "x-placenext-proxy" || diffs[1].Component != "url" {
+        t.Fatal("expect header and url difference got", diffs)
+    }
+    if diffs[1].Expected != "http://api.aimmatic.com/v1/placeNextIngest/PointImport?b=2" || diffs[1].Actual != client.URL {
+        t.Error("expect url difference got", diffs[1])
+    }
+    if s := diffs[0].String(); s != `x-placenext-proxy: expected "1" got ""` {
+        t.Error("expect difference description got", s)
+    }
+}
+
+func TestHeaderOrderPrefix(t *testing.T) {
+    req, _ := http.NewRequest("GET", "https://api.aimmatic.com/v1/insights/nss", nil)
+    req.Header.Set("X-Placenext-Date-Local", "2006-01-02T22:04:05+07:00")
+    req.Header.Set(XPlacenextDate, "Mon, 02 Jan 2006 15:04:05 UTC")
+    req.Header.Set("X-Placenext-Tag2", "b")
+    req.Header.Add("X-Placenext-Tag", "z")
+    req.Header.Add("X-Placenext-Tag", "a")
+    // a name that is the prefix of another come first, as the header are sorted by name
+    expect := "x-placenext-date:Mon, 02 Jan 2006 15:04:05 UTC" +
+        "x-placenext-date-local:2006-01-02T22:04:05+07:00" +
+        "x-placenext-tag:a,z" +
+        "x-placenext-tag2:b"
+    if concat := concatenateHeader(req); concat != expect {
+        t.Errorf("expect header\n%s\ngot\n%s", expect, concat)
+    }
+    sts, _, _, err := StringToSign(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !strings.Contains(string(sts), "\n"+expect+"\n") {
+        t.Errorf("expect string to sign to contain the sorted header got\n%s", sts)
+    }
+}
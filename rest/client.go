@@ -17,10 +17,18 @@ limitations under the License.
 package rest
 
 import (
+    "context"
+    "errors"
+    "log/slog"
     "net/http"
     "sync"
+    "sync/atomic"
     "time"
     "fmt"
+    "io"
+    "encoding/base64"
+
+    "github.com/aimmatic/aimmatic-go-sdk-placenext/telemetry"
 )
 
 // RESTClient imposes common placenext API conventions on a set of resource paths.
@@ -32,8 +40,48 @@ import (
 type Client struct {
     *http.Client
     config Config
+
+    // guard the fields below
+    mu sync.RWMutex
+    // default timeout apply to the operation that has no timeout of its own
+    defaultTimeout time.Duration
+    // timeout per operation name, see WithOperation
+    timeouts map[string]time.Duration
+    // retry policy, nil disable retry
+    retryPolicy *RetryPolicy
+    // signer of the request, nil sign with the secret key
+    signer Signer
+    // provider of the credentials, nil use the config credentials
+    credentials CredentialsProvider
+    // reason the config is not available, see LoadDefaultConfig
+    configErr error
+    // error of the options given to NewRestClient
+    err error
+    // interceptors run before and after the request is signed, see Use
+    beforeSign []Interceptor
+    afterSign  []Interceptor
+    // logger of the exchanges, nil disable logging
+    logger *slog.Logger
+    // dump the requests and responses to the logger
+    debug bool
+    // instrumentation of the requests, nil disable it
+    tracer     telemetry.Tracer
+    meter      telemetry.Meter
+    propagator telemetry.Propagator
+    // limiters of the requests per api key, nil disable limiting
+    limiters *Limiters
+    // circuit breakers per endpoint, nil disable them
+    breakers *Breakers
+    // clock of the signatures, nil use time.Now
+    clock func() time.Time
+    // offset of the server clock learned from the response Date header in nanoseconds
+    clockOffset atomic.Int64
 }
 
+// DefaultTimeout is the timeout apply to a request which context does not have
+// a deadline and its operation does not have a specific timeout.
+const DefaultTimeout = 30 * time.Second
+
 // a default rest client
 var defaultRestClient *Client
 
@@ -41,56 +89,230 @@ var defaultRestClient *Client
 var once sync.Once
 
 // DefaultClient return a Client with the default config. The default config
-// will contain an apikey and secret key from the variable environment.
+// will contain an apikey and secret key from the variable environment. Without
+// variable environment, the credentials are resolved with DefaultCredentialsChain.
+// If the variable environment is invalid, every request fail with the reason
+// instead of being sent.
 func DefaultClient() *Client {
     once.Do(func() {
-        defaultRestClient = &Client{
-            Client: http.DefaultClient,
-            config: DefaultConfig(),
+        config, err := defaultConfig()
+        switch {
+        case err == nil:
+            defaultRestClient = NewRestClient(config)
+        case errors.Is(err, ErrNotConfigured):
+            // resolve the credentials from the shared credentials file
+            opts, optsErr := envConfigOptions()
+            if optsErr != nil {
+                defaultRestClient = NewRestClient(nil)
+                defaultRestClient.configErr = optsErr
+                break
+            }
+            defaultRestClient = NewRestClient(NewKeyConfig("", opts...))
+            defaultRestClient.credentials = DefaultCredentialsChain()
+        default:
+            defaultRestClient = NewRestClient(nil)
+            defaultRestClient.configErr = err
         }
     })
     return defaultRestClient
 }
 
-// NewRestClient create a new rest client of default http.DefaultClient with
-// the given config. This function is useful when you need to provide a different
-// apikey and secret at runtime.
-func NewRestClient(config Config) *Client {
-    return &Client{
-        Client: http.DefaultClient,
-        config: config,
+// NewRestClient create a new rest client with the given config. This function is useful
+// when you need to provide a different apikey and secret at runtime. The requests are
+// sent with http.DefaultTransport unless the options say otherwise. An invalid combination
+// of options make every request fail with the reason.
+func NewRestClient(config Config, opts ...Option) *Client {
+    o := &clientOptions{}
+    for _, opt := range opts {
+        opt(o)
+    }
+    client := &Client{
+        config:         config,
+        defaultTimeout: DefaultTimeout,
+        timeouts:       make(map[string]time.Duration),
+        logger:         o.logger,
+        debug:          o.debug,
+        tracer:         o.tracer,
+        meter:          o.meter,
+        propagator:     o.propagator,
+        limiters:       o.limiters,
+        breakers:       o.breakers,
+        clock:          o.clock,
+    }
+    if o.timeout != nil {
+        client.defaultTimeout = *o.timeout
+    }
+    if client.Client, client.err = o.newHTTPClient(); client.err != nil {
+        client.Client = &http.Client{}
+    }
+    return client
+}
+
+// SetTimeout set the timeout of the given operation. The timeout is only applied
+// when the request context does not already have a deadline. An empty operation
+// set the default timeout of all operation. A zero or negative timeout disable it.
+func (c *Client) SetTimeout(operation string, timeout time.Duration) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if operation == "" {
+        c.defaultTimeout = timeout
+    } else {
+        c.timeouts[operation] = timeout
+    }
+}
+
+// Timeout return the timeout that will be applied to the given operation
+func (c *Client) Timeout(operation string) time.Duration {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    if timeout, ok := c.timeouts[operation]; ok {
+        return timeout
     }
+    return c.defaultTimeout
 }
 
-// Override Do request of the default http client to add custom header and api authorization
+// Override Do request of the default http client to add custom header and api authorization.
+// The request context is honored, if it has no deadline then the operation timeout is applied
+// until the response body is closed. A failed request is retried according to the retry policy.
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
-    if err := addHeader(req, c.config); err != nil {
-        return nil, err
+    if c.err != nil {
+        return nil, c.err
+    }
+    var cancel context.CancelFunc
+    ctx := req.Context()
+    if _, ok := ctx.Deadline(); !ok {
+        if timeout := c.Timeout(OperationFromContext(ctx)); timeout > 0 {
+            ctx, cancel = context.WithTimeout(ctx, timeout)
+            req = req.WithContext(ctx)
+        }
     }
-    return c.Client.Do(req)
+    setIdempotencyKey(req)
+    req, finish := c.instrument(req)
+    resp, err := c.send(req)
+    finish(resp, err)
+    if cancel != nil {
+        if err != nil {
+            cancel()
+        } else {
+            resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+        }
+    }
+    return resp, err
+}
+
+// DoContext is similar to Do but bound the request to the given context
+func (c *Client) DoContext(ctx context.Context, req *http.Request) (*http.Response, error) {
+    return c.Do(req.WithContext(ctx))
+}
+
+// cancelBody release the timeout context once the response body is closed
+type cancelBody struct {
+    io.ReadCloser
+    cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+    err := b.ReadCloser.Close()
+    b.cancel()
+    return err
+}
+
+// operationKey is the context key of the operation name
+type operationKey struct{}
+
+// WithOperation return a copy of ctx that carry the given operation name. The operation
+// name is used by the Client to select the timeout of the request.
+func WithOperation(ctx context.Context, operation string) context.Context {
+    return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// OperationFromContext return the operation name of the given context or empty string
+func OperationFromContext(ctx context.Context) string {
+    operation, _ := ctx.Value(operationKey{}).(string)
+    return operation
+}
+
+// SetSigner set the signer of the client requests. A nil signer sign with the secret
+// key of the credentials provider or the client config.
+func (c *Client) SetSigner(signer Signer) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.signer = signer
+}
+
+// Signer return the signer set with SetSigner or nil
+func (c *Client) Signer() Signer {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.signer
 }
 
 // Config return a the config of the current client
 func (c *Client) Config() Config {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
     return c.config
 }
 
-// add custom header and api authorization
-func addHeader(r *http.Request, config Config) error {
+// SetConfig replace the config of the client. It is safe to call while requests are
+// in flight, a request already sent keep the credentials it was signed with.
+func (c *Client) SetConfig(config Config) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.config, c.configErr = config, nil
+}
+
+// Host return the placenext api server of the client config. Without config, it
+// return PLACENEXT_ADDRESS variable environment or the default api server.
+func (c *Client) Host() string {
+    if config := c.Config(); config != nil {
+        return config.GetPlaceNextHost()
+    }
+    return defaultHost()
+}
+
+// SignatureScheme return the signature scheme of the client config, SignatureV1 if
+// the client has no config
+func (c *Client) SignatureScheme() SignatureScheme {
+    if config := c.Config(); config != nil {
+        return signatureSchemeOf(config)
+    }
+    return SignatureV1
+}
+
+// add custom header and api authorization signed by the given signer at the given time
+// with the given scheme
+func signRequest(r *http.Request, apiKey string, signer Signer, now time.Time, scheme SignatureScheme) error {
     // user agent
     r.Header.Set(UserAgent, defaultAgent)
     // add date
-    date := time.Now().UTC().Format(time.RFC1123)
+    date := now.UTC().Format(time.RFC1123)
     r.Header.Set(Date, date)
     r.Header.Set(XPlacenextDate, date)
     // calculate signature
-    _, _, signatureHashB64, md5hash, err := ComputeSignature(r, config.GetSecretKey())
+    var stringToSign []byte
+    var digestHeader, digest, prefix string
+    var err error
+    switch scheme {
+    case SignatureV2:
+        stringToSign, digest, err = StringToSignV2(r)
+        digestHeader, prefix = ContentDigest, authorizationV2
+    case SignatureV1, 0:
+        stringToSign, _, digest, err = StringToSign(r)
+        digestHeader, prefix = ContentMD5, authorizationV1
+    default:
+        err = fmt.Errorf("%w: %d", ErrUnknownSignatureScheme, scheme)
+    }
+    if err != nil {
+        return err
+    }
+    signature, err := signer.Sign(r.Context(), stringToSign)
     if err != nil {
         return err
     }
-    if md5hash != "" {
-        r.Header.Set(ContentMD5, md5hash)
+    if digest != "" {
+        r.Header.Set(digestHeader, digest)
     }
-    r.Header.Set(Authorization, fmt.Sprintf("AimMatic %s:%s", config.GetApiKey(), signatureHashB64))
+    r.Header.Set(Authorization, prefix+apiKey+":"+base64.RawStdEncoding.EncodeToString(signature))
     return nil
 }
@@ -14,6 +14,7 @@ limitations under the License.
 package rest
 
 import (
+    "context"
     "testing"
     "net/http"
     "net/http/httptest"
@@ -24,6 +25,7 @@ import (
     "io/ioutil"
     "net/http/httputil"
     "net/url"
+    "errors"
 )
 
 func simpleHandler(w http.ResponseWriter, r *http.Request) {
@@ -103,7 +105,7 @@ func TestAuthentication(t *testing.T) {
     if err != nil {
         t.Fatal(err)
     }
-    addHeader(req, config)
+    signRequest(req, config.GetApiKey(), NewHMACSigner(config.GetSecretKey()), time.Now(), signatureSchemeOf(config))
     // process test
     resp, err := client.Do(req)
     if err != nil {
@@ -125,7 +127,7 @@ func TestAuthentication(t *testing.T) {
     if err != nil {
         t.Fatal(err)
     }
-    addHeader(req, config)
+    signRequest(req, config.GetApiKey(), NewHMACSigner(config.GetSecretKey()), time.Now(), signatureSchemeOf(config))
     // process test
     resp, err = client.Do(req)
     if err != nil {
@@ -153,7 +155,7 @@ func TestAuthentication(t *testing.T) {
     if err != nil {
         t.Fatal(err)
     }
-    addHeader(req, config)
+    signRequest(req, config.GetApiKey(), NewHMACSigner(config.GetSecretKey()), time.Now(), signatureSchemeOf(config))
     // process test
     resp, err = serverHttpsFrontend.Client().Do(req)
     if err != nil {
@@ -167,3 +169,36 @@ func TestAuthentication(t *testing.T) {
         t.Error("authentication failed due to", string(b))
     }
 }
+
+func TestClientOperationTimeout(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        select {
+        case <-r.Context().Done():
+        case <-time.After(time.Second):
+        }
+    }))
+    defer server.Close()
+    config, _ := NewConfig(apiKey, secretKey)
+    client := NewRestClient(config)
+    client.SetTimeout("slow", 50*time.Millisecond)
+    if timeout := client.Timeout("other"); timeout != DefaultTimeout {
+        t.Error("expect default timeout", DefaultTimeout, "got", timeout)
+    }
+    // operation timeout is applied when context has no deadline
+    req, _ := http.NewRequestWithContext(WithOperation(context.Background(), "slow"), "GET", server.URL, nil)
+    start := time.Now()
+    if _, err := client.Do(req); err == nil {
+        t.Error("expect timeout error")
+    } else if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+        t.Error("timeout was not applied, request took", elapsed)
+    }
+    // caller deadline take precedence over operation timeout
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    req, _ = http.NewRequest("GET", server.URL, nil)
+    if _, err := client.DoContext(WithOperation(ctx, "slow"), req); err == nil {
+        t.Error("expect deadline error")
+    } else if !errors.Is(err, context.DeadlineExceeded) {
+        t.Error("expect context deadline exceeded got", err)
+    }
+}
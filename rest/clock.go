@@ -0,0 +1,125 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "io"
+    "io/ioutil"
+    "net/http"
+    "time"
+)
+
+// skewTolerance is the difference with the server clock that is ignored, the response
+// Date header has a resolution of a second
+const skewTolerance = 2 * time.Second
+
+// WithClock set the clock of the signatures, see Client.SetClock
+func WithClock(now func() time.Time) Option {
+    return func(o *clientOptions) {
+        o.clock = now
+    }
+}
+
+// SetClock set the clock of the signatures, a nil clock use time.Now. The offset of
+// the server clock learned from the responses is added to it.
+func (c *Client) SetClock(now func() time.Time) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.clock = now
+}
+
+// ClockOffset return the offset of the server clock relative to the client clock
+// learned from the response Date header
+func (c *Client) ClockOffset() time.Duration {
+    return time.Duration(c.clockOffset.Load())
+}
+
+// localNow return the time of the client clock
+func (c *Client) localNow() time.Time {
+    c.mu.RLock()
+    clock := c.clock
+    c.mu.RUnlock()
+    if clock == nil {
+        return time.Now()
+    }
+    return clock()
+}
+
+// observeDate learn the offset of the server clock from the Date header of the response.
+// It return the offset and false if the response has no Date header.
+func (c *Client) observeDate(resp *http.Response) (time.Duration, bool) {
+    date, err := http.ParseTime(resp.Header.Get(Date))
+    if err != nil {
+        return c.ClockOffset(), false
+    }
+    observed := date.Sub(c.localNow())
+    if current := c.ClockOffset(); absDuration(observed-current) <= skewTolerance {
+        return current, true
+    }
+    c.clockOffset.Store(int64(observed))
+    return observed, true
+}
+
+// absDuration return the absolute value of d
+func absDuration(d time.Duration) time.Duration {
+    if d < 0 {
+        return -d
+    }
+    return d
+}
+
+// signInterceptor is the built-in interceptor that set the date, user agent and
+// authorization of the request. The date is corrected by the offset of the server
+// clock. A request rejected with 401 Unauthorized while the response show the clock
+// offset changed is signed again with the corrected date and sent once more.
+func (c *Client) signInterceptor(sign *signing) Interceptor {
+    return func(req *http.Request, next Exchange) (*http.Response, error) {
+        offset := c.ClockOffset()
+        if err := signRequest(req, sign.apiKey, sign.signer, c.localNow().Add(offset), sign.scheme); err != nil {
+            return nil, err
+        }
+        resp, err := next(req)
+        if err != nil || resp.StatusCode != http.StatusUnauthorized {
+            if err == nil {
+                c.observeDate(resp)
+            }
+            return resp, err
+        }
+        corrected, ok := c.observeDate(resp)
+        if !ok || absDuration(corrected-offset) <= skewTolerance {
+            return resp, err
+        }
+        // the body must be sent again
+        retry := req.Clone(req.Context())
+        if req.Body != nil && req.Body != http.NoBody {
+            if req.GetBody == nil {
+                return resp, err
+            }
+            body, bodyErr := req.GetBody()
+            if bodyErr != nil {
+                return resp, err
+            }
+            retry.Body = body
+        }
+        io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+        resp.Body.Close()
+        if err = signRequest(retry, sign.apiKey, sign.signer, c.localNow().Add(corrected), sign.scheme); err != nil {
+            return nil, err
+        }
+        return next(retry)
+    }
+}
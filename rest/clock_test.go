@@ -0,0 +1,58 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "time"
+)
+
+func TestClientClockSkew(t *testing.T) {
+    secret, _ := GetSecretKeyAsByte(secretKey)
+    verifier := NewVerifier(StaticKeyStore{apiKey: secret})
+    calls := 0
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        calls++
+        verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+    }))
+    defer server.Close()
+    config, _ := NewConfig(apiKey, secretKey)
+    // the client clock is 10 minutes late
+    client := NewRestClient(config, WithClock(func() time.Time { return time.Now().Add(-10 * time.Minute) }))
+    send := func() int {
+        req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/placeNextIngest/PointImport", strings.NewReader(body))
+        req.Header.Set(ContentType, MediaJson)
+        // identical requests signed within the same second would be rejected as replay
+        req.Header.Set(XPlacenextIdempotencyKey, NewIdempotencyKey())
+        resp, err := client.Do(req)
+        if err != nil {
+            t.Fatal(err)
+        }
+        resp.Body.Close()
+        return resp.StatusCode
+    }
+    if status := send(); status != http.StatusOK || calls != 2 {
+        t.Error("expect request to be signed again with the server time got", status, calls)
+    }
+    if offset := client.ClockOffset(); offset < 9*time.Minute || offset > 11*time.Minute {
+        t.Error("expect 10 minutes clock offset got", offset)
+    }
+    // the offset apply to the next requests
+    if status := send(); status != http.StatusOK || calls != 3 {
+        t.Error("expect request to be signed with the server time got", status, calls)
+    }
+}
@@ -19,6 +19,9 @@ package rest
 import (
     "os"
     "errors"
+    "fmt"
+    "strings"
+    "sync"
 )
 
 // Config holds the common attributes that can be passed to rest client
@@ -50,6 +53,19 @@ type configImpl struct {
 
     // userAgent is an optional field that specifies the caller of this request.
     userAgent string
+
+    // scheme of the request signature, SignatureV1 by default
+    scheme SignatureScheme
+}
+
+// ConfigOption customize a Config created with NewConfig or NewKeyConfig
+type ConfigOption func(c *configImpl)
+
+// WithSignatureScheme sign the requests with the given scheme
+func WithSignatureScheme(scheme SignatureScheme) ConfigOption {
+    return func(c *configImpl) {
+        c.scheme = scheme
+    }
 }
 
 // GetApiKey return the api key
@@ -72,53 +88,165 @@ func (c *configImpl) GetUserAgent() string {
     return c.userAgent
 }
 
-// Default config that initial when application start or a config form SetConfig
+// GetSignatureScheme return the scheme of the request signature
+func (c *configImpl) GetSignatureScheme() SignatureScheme {
+    if c.scheme == 0 {
+        return SignatureV1
+    }
+    return c.scheme
+}
+
+// ErrNotConfigured is returned when the placenext api key and secret key are not available
+var ErrNotConfigured = errors.New("placenext is not configured")
+
+// Default config that is loaded lazily from the variable environment or a config form SetConfig
 var defConf Config
 
-// initialize placenext configuration with apikey and secret
-// the apikey and secret must available via variable environment
-func init() {
-    if os.Getenv(PLACENEXT_SECRETKEY) == "" || os.Getenv(PLACENEXT_APIKEY) == "" {
-        return
+// error of loading the default config
+var defConfErr error
+
+// whether the default config was loaded or set
+var defConfLoaded bool
+
+// guard defConf, defConfErr and defConfLoaded
+var defConfMu sync.Mutex
+
+// LoadDefaultConfig load a config from PLACENEXT_APIKEY and PLACENEXT_SECRETKEY variable
+// environment. It return an error wrapping ErrNotConfigured when the variables are not set
+// or a descriptive error when a key is malformed.
+func LoadDefaultConfig() (Config, error) {
+    apiKey, secretKey := os.Getenv(PLACENEXT_APIKEY), os.Getenv(PLACENEXT_SECRETKEY)
+    switch {
+    case apiKey == "" && secretKey == "":
+        return nil, fmt.Errorf("%w: %s and %s variable environment are not set", ErrNotConfigured, PLACENEXT_APIKEY, PLACENEXT_SECRETKEY)
+    case apiKey == "":
+        return nil, fmt.Errorf("%w: %s variable environment is not set", ErrNotConfigured, PLACENEXT_APIKEY)
+    case secretKey == "":
+        return nil, fmt.Errorf("%w: %s variable environment is not set", ErrNotConfigured, PLACENEXT_SECRETKEY)
     }
-    var err error
-    if defConf, err = NewConfig(os.Getenv(PLACENEXT_APIKEY), os.Getenv(PLACENEXT_SECRETKEY)); err != nil {
-        panic("invalid secret key " + err.Error())
+    opts, err := envConfigOptions()
+    if err != nil {
+        return nil, err
     }
+    config, err := NewConfig(apiKey, secretKey, opts...)
+    if err != nil {
+        return nil, fmt.Errorf("invalid placenext variable environment: %w", err)
+    }
+    return config, nil
+}
+
+// defaultConfig load the default config once unless it was set with SetConfig
+func defaultConfig() (Config, error) {
+    defConfMu.Lock()
+    defer defConfMu.Unlock()
+    if !defConfLoaded {
+        defConf, defConfErr = LoadDefaultConfig()
+        defConfLoaded = true
+    }
+    return defConf, defConfErr
 }
 
 // DefaultConfig return a default config where api key and secret key is reading from variable environment.
-// if variable environment is not available then default config will return nil otherwise a config will return
-// Note: variable environment must export before application running if not default will be nil
+// The variable environment is read on the first call, if it is not available or invalid then nil is
+// returned, use LoadDefaultConfig to know the reason.
 func DefaultConfig() Config {
-    return defConf
+    config, _ := defaultConfig()
+    return config
 }
 
-// NewConfig create new configure based on the given api key and secret key.
-func NewConfig(apiKey, secretKey string) (Config, error) {
+// ValidateApiKey check the api key is not empty and can be used in the authorization header
+func ValidateApiKey(apiKey string) error {
+    if apiKey == "" {
+        return errors.New("api key is empty")
+    }
+    if strings.ContainsAny(apiKey, ": \t\r\n") {
+        return errors.New("api key must not contain colon or whitespace")
+    }
+    return nil
+}
+
+// ValidateSecretKey check the secret key is a non empty base64 (no padding) string and
+// return its decoded bytes
+func ValidateSecretKey(secretKey string) ([]byte, error) {
+    if secretKey == "" {
+        return nil, errors.New("secret key is empty")
+    }
     rawSecretKey, err := GetSecretKeyAsByte(secretKey)
+    if err != nil {
+        return nil, errors.New("secret key is not base64 without padding: " + err.Error())
+    }
+    return rawSecretKey, nil
+}
+
+// NewConfig create new configure based on the given api key and secret key.
+// Both keys are validated, see ValidateApiKey and ValidateSecretKey.
+func NewConfig(apiKey, secretKey string, opts ...ConfigOption) (Config, error) {
+    if err := ValidateApiKey(apiKey); err != nil {
+        return nil, errors.New("invalid api key: " + err.Error())
+    }
+    rawSecretKey, err := ValidateSecretKey(secretKey)
     if err != nil {
         err = errors.New("invalid secret " + err.Error())
         return nil, err
     }
-    var placenextAddr string
-    // if PLACENEXT_ADDRESS not given then default is set
-    if placenextAddr = os.Getenv(PLACENEXT_ADDRESS); placenextAddr == "" {
-        placenextAddr = scheme + "://" + domain
-    }
-    return &configImpl{
+    config := &configImpl{
         apiKey:       apiKey,
         secretKey:    secretKey,
         rawSecretKey: rawSecretKey,
-        host:         placenextAddr,
+        host:         defaultHost(),
         userAgent:    defaultAgent,
-    }, nil
+    }
+    for _, opt := range opts {
+        opt(config)
+    }
+    return config, nil
+}
+
+// defaultHost return PLACENEXT_ADDRESS variable environment or the placenext api server
+func defaultHost() string {
+    if placenextAddr := os.Getenv(PLACENEXT_ADDRESS); placenextAddr != "" {
+        return placenextAddr
+    }
+    return scheme + "://" + domain
+}
+
+// envConfigOptions return the config options set by variable environment
+func envConfigOptions() ([]ConfigOption, error) {
+    var opts []ConfigOption
+    if name := os.Getenv(PLACENEXT_SIGNATURE_SCHEME); name != "" {
+        scheme, err := ParseSignatureScheme(name)
+        if err != nil {
+            return nil, fmt.Errorf("invalid %s variable environment: %w", PLACENEXT_SIGNATURE_SCHEME, err)
+        }
+        opts = append(opts, WithSignatureScheme(scheme))
+    }
+    return opts, nil
+}
+
+// NewKeyConfig create a configuration which only hold the api key. Use it with a Signer
+// such as AgentSigner so the secret key does not live in the application process.
+// Without a signer, requests fail with an error wrapping ErrNotConfigured.
+func NewKeyConfig(apiKey string, opts ...ConfigOption) Config {
+    config := &configImpl{
+        apiKey:    apiKey,
+        host:      defaultHost(),
+        userAgent: defaultAgent,
+    }
+    for _, opt := range opts {
+        opt(config)
+    }
+    return config
 }
 
 // SetConfig set the given configuration globally as well as default Client
 // If you need a different api key and secret key for each request to placenext api
 // you must create new Client with your new config and then use the new Client with our function.
 func SetConfig(config Config) {
-    defConf = config
-    DefaultClient().config = config
+    defConfMu.Lock()
+    defConf, defConfErr, defConfLoaded = config, nil, true
+    defConfMu.Unlock()
+    client := DefaultClient()
+    client.SetConfig(config)
+    // the given config credentials replace the default credentials chain
+    client.SetCredentialsProvider(nil)
 }
@@ -0,0 +1,68 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "errors"
+    "net/http"
+    "strings"
+)
+
+func TestLoadDefaultConfig(t *testing.T) {
+    t.Setenv(PLACENEXT_APIKEY, "")
+    t.Setenv(PLACENEXT_SECRETKEY, "")
+    if _, err := LoadDefaultConfig(); !errors.Is(err, ErrNotConfigured) {
+        t.Error("expect not configured error got", err)
+    }
+    t.Setenv(PLACENEXT_APIKEY, apiKey)
+    if _, err := LoadDefaultConfig(); !errors.Is(err, ErrNotConfigured) || !strings.Contains(err.Error(), PLACENEXT_SECRETKEY) {
+        t.Error("expect missing secret key error got", err)
+    }
+    t.Setenv(PLACENEXT_SECRETKEY, "not base64!")
+    if _, err := LoadDefaultConfig(); err == nil || errors.Is(err, ErrNotConfigured) {
+        t.Error("expect invalid secret key error got", err)
+    }
+    t.Setenv(PLACENEXT_SECRETKEY, secretKey)
+    config, err := LoadDefaultConfig()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if config.GetApiKey() != apiKey {
+        t.Error("wrong api key", config.GetApiKey())
+    }
+}
+
+func TestNewConfigValidation(t *testing.T) {
+    for _, keys := range [][2]string{{"", secretKey}, {"api:key", secretKey}, {"api key", secretKey}, {apiKey, ""}, {apiKey, secretKey + "=="}} {
+        if _, err := NewConfig(keys[0], keys[1]); err == nil {
+            t.Errorf("expect error for api key %q and secret key %q", keys[0], keys[1])
+        }
+    }
+}
+
+func TestClientNotConfigured(t *testing.T) {
+    client := NewRestClient(nil)
+    req, _ := http.NewRequest(http.MethodGet, client.Host()+"/v1/insights/nss", nil)
+    if _, err := client.Do(req); !errors.Is(err, ErrNotConfigured) {
+        t.Error("expect not configured error got", err)
+    }
+    // an empty credentials chain report why it has no credentials
+    client.SetCredentialsProvider(NewChainProvider(EnvProvider{}))
+    t.Setenv(PLACENEXT_APIKEY, "")
+    t.Setenv(PLACENEXT_SECRETKEY, "")
+    if _, err := client.Do(req); !errors.Is(err, ErrNotConfigured) || !errors.Is(err, ErrNoCredentials) {
+        t.Error("expect not configured error got", err)
+    }
+}
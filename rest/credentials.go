@@ -0,0 +1,308 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "bufio"
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+)
+
+// ErrNoCredentials is returned by a CredentialsProvider that has no credentials to offer.
+// A ChainProvider try the next provider on this error.
+var ErrNoCredentials = errors.New("no placenext credentials")
+
+// Credentials is a pair of api key and raw secret key
+type Credentials struct {
+    ApiKey    string
+    SecretKey []byte
+    // Source describe where the credentials come from
+    Source string
+}
+
+// CredentialsProvider retrieve the credentials to sign a request. Retrieve is called
+// for every request and must be safe for concurrent use.
+type CredentialsProvider interface {
+    Retrieve(ctx context.Context) (*Credentials, error)
+}
+
+// newCredentials decode the base64 secret key into credentials
+func newCredentials(apiKey, secretKey, source string) (*Credentials, error) {
+    if apiKey == "" || secretKey == "" {
+        return nil, fmt.Errorf("%w in %s: api key and secret key are required", ErrNoCredentials, source)
+    }
+    rawSecretKey, err := GetSecretKeyAsByte(secretKey)
+    if err != nil {
+        return nil, fmt.Errorf("invalid secret key in %s: %v", source, err)
+    }
+    return &Credentials{ApiKey: apiKey, SecretKey: rawSecretKey, Source: source}, nil
+}
+
+// StaticProvider always return the same credentials
+type StaticProvider struct {
+    credentials *Credentials
+}
+
+// NewStaticProvider create a provider of the given api key and base64 secret key
+func NewStaticProvider(apiKey, secretKey string) (*StaticProvider, error) {
+    credentials, err := newCredentials(apiKey, secretKey, "static")
+    if err != nil {
+        return nil, err
+    }
+    return &StaticProvider{credentials: credentials}, nil
+}
+
+// Retrieve return the static credentials
+func (p *StaticProvider) Retrieve(ctx context.Context) (*Credentials, error) {
+    return p.credentials, nil
+}
+
+// EnvProvider read the credentials from PLACENEXT_APIKEY and PLACENEXT_SECRETKEY
+// variable environment
+type EnvProvider struct{}
+
+// Retrieve read the variable environment
+func (EnvProvider) Retrieve(ctx context.Context) (*Credentials, error) {
+    return newCredentials(os.Getenv(PLACENEXT_APIKEY), os.Getenv(PLACENEXT_SECRETKEY), "environment")
+}
+
+// FileProvider read the credentials of a profile from a shared credentials file.
+// The file is an ini file where each section is a profile:
+//
+//   [default]
+//   apikey = ...
+//   secretkey = ...
+//
+// The file is read again when its modification time change.
+type FileProvider struct {
+    // Path of the credentials file, if empty PLACENEXT_CREDENTIALS_FILE variable
+    // environment or ~/.placenext/credentials is used
+    Path string
+    // Profile is the section to read, if empty PLACENEXT_PROFILE variable environment
+    // or default is used
+    Profile string
+
+    mu          sync.Mutex
+    modTime     time.Time
+    credentials *Credentials
+}
+
+// NewFileProvider create a provider reading the given profile of the given file
+func NewFileProvider(path, profile string) *FileProvider {
+    return &FileProvider{Path: path, Profile: profile}
+}
+
+// path return the credentials file path
+func (p *FileProvider) path() string {
+    if p.Path != "" {
+        return p.Path
+    }
+    if path := os.Getenv(PLACENEXT_CREDENTIALS_FILE); path != "" {
+        return path
+    }
+    home, err := os.UserHomeDir()
+    if err != nil {
+        return ""
+    }
+    return filepath.Join(home, ".placenext", "credentials")
+}
+
+// profile return the profile name
+func (p *FileProvider) profile() string {
+    if p.Profile != "" {
+        return p.Profile
+    }
+    if profile := os.Getenv(PLACENEXT_PROFILE); profile != "" {
+        return profile
+    }
+    return "default"
+}
+
+// Retrieve read the credentials of the profile
+func (p *FileProvider) Retrieve(ctx context.Context) (*Credentials, error) {
+    path := p.path()
+    if path == "" {
+        return nil, fmt.Errorf("%w: home directory is unknown", ErrNoCredentials)
+    }
+    info, err := os.Stat(path)
+    if os.IsNotExist(err) {
+        return nil, fmt.Errorf("%w: %s does not exist", ErrNoCredentials, path)
+    } else if err != nil {
+        return nil, err
+    }
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if p.credentials != nil && info.ModTime().Equal(p.modTime) {
+        return p.credentials, nil
+    }
+    profile := p.profile()
+    values, err := readProfile(path, profile)
+    if err != nil {
+        return nil, err
+    }
+    credentials, err := newCredentials(values["apikey"], values["secretkey"], path+" profile "+profile)
+    if err != nil {
+        return nil, err
+    }
+    p.credentials, p.modTime = credentials, info.ModTime()
+    return credentials, nil
+}
+
+// readProfile read the keys of a section of an ini file, keys are lower case
+func readProfile(path, profile string) (map[string]string, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    values := make(map[string]string)
+    found, inProfile := false, false
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || line[0] == '#' || line[0] == ';' {
+            continue
+        }
+        if line[0] == '[' && line[len(line)-1] == ']' {
+            inProfile = strings.TrimSpace(line[1:len(line)-1]) == profile
+            found = found || inProfile
+            continue
+        }
+        if !inProfile {
+            continue
+        }
+        if index := strings.IndexByte(line, '='); index > 0 {
+            values[strings.ToLower(strings.TrimSpace(line[:index]))] = strings.TrimSpace(line[index+1:])
+        }
+    }
+    if err = scanner.Err(); err != nil {
+        return nil, err
+    }
+    if !found {
+        return nil, fmt.Errorf("%w: profile %s not found in %s", ErrNoCredentials, profile, path)
+    }
+    return values, nil
+}
+
+// ChainProvider try each provider in order and return the first credentials found.
+// A provider that fail with an error other than ErrNoCredentials stop the chain.
+type ChainProvider struct {
+    Providers []CredentialsProvider
+}
+
+// NewChainProvider create a provider trying the given providers in order
+func NewChainProvider(providers ...CredentialsProvider) *ChainProvider {
+    return &ChainProvider{Providers: providers}
+}
+
+// DefaultCredentialsChain return the chain of the variable environment then the
+// shared credentials file
+func DefaultCredentialsChain() *ChainProvider {
+    return NewChainProvider(EnvProvider{}, &FileProvider{})
+}
+
+// Retrieve return the credentials of the first provider that has some
+func (p *ChainProvider) Retrieve(ctx context.Context) (*Credentials, error) {
+    reasons := make([]string, 0, len(p.Providers))
+    for _, provider := range p.Providers {
+        credentials, err := provider.Retrieve(ctx)
+        if err == nil {
+            return credentials, nil
+        }
+        if !errors.Is(err, ErrNoCredentials) {
+            return nil, err
+        }
+        reasons = append(reasons, err.Error())
+    }
+    return nil, fmt.Errorf("%w: %s", ErrNoCredentials, strings.Join(reasons, "; "))
+}
+
+// SetCredentialsProvider set the provider of the credentials used to sign every request.
+// A nil provider use the api key and secret key of the client config.
+func (c *Client) SetCredentialsProvider(provider CredentialsProvider) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.credentials = provider
+}
+
+// CredentialsProvider return the credentials provider of the client or nil
+func (c *Client) CredentialsProvider() CredentialsProvider {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.credentials
+}
+
+// signing hold what is needed to sign the attempts of a request
+type signing struct {
+    apiKey      string
+    signer      Signer
+    scheme      SignatureScheme
+    provider    CredentialsProvider
+    credentials *Credentials
+}
+
+// expired report whether the credentials of the provider expired, see RotatingProvider
+func (s *signing) expired() bool {
+    if e, ok := s.provider.(expirer); ok && s.credentials != nil {
+        return e.Expired(s.credentials)
+    }
+    return false
+}
+
+// resolveSigning return the api key and signer of a request. The credentials provider
+// take precedence over the config, a signer set with SetSigner take precedence over
+// the secret key.
+func (c *Client) resolveSigning(ctx context.Context) (*signing, error) {
+    c.mu.RLock()
+    provider, customSigner, config, configErr := c.credentials, c.signer, c.config, c.configErr
+    c.mu.RUnlock()
+    s := &signing{provider: provider, scheme: SignatureV1}
+    if config != nil {
+        s.scheme = signatureSchemeOf(config)
+    }
+    switch {
+    case provider != nil:
+        credentials, err := provider.Retrieve(ctx)
+        if errors.Is(err, ErrNoCredentials) {
+            return nil, fmt.Errorf("%w: %w", ErrNotConfigured, err)
+        } else if err != nil {
+            return nil, err
+        }
+        s.apiKey, s.signer, s.credentials = credentials.ApiKey, NewHMACSigner(credentials.SecretKey), credentials
+    case config != nil:
+        secret := config.GetSecretKey()
+        if len(secret) == 0 && customSigner == nil {
+            // a key config such as NewKeyConfig need a signer
+            return nil, fmt.Errorf("%w: the config has no secret key and no signer is set", ErrNotConfigured)
+        }
+        s.apiKey, s.signer = config.GetApiKey(), NewHMACSigner(secret)
+    case configErr != nil:
+        return nil, configErr
+    default:
+        return nil, ErrNotConfigured
+    }
+    if customSigner != nil {
+        s.signer = customSigner
+    }
+    return s, nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+)
+
+func TestChainProvider(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "credentials")
+    content := "# placenext credentials\n[default]\napikey = default-key\nsecretkey = " + secretKey + "\n\n[staging]\napikey = staging-key\nsecretkey = " + secretKey + "\n"
+    if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+        t.Fatal(err)
+    }
+    t.Setenv(PLACENEXT_APIKEY, "")
+    t.Setenv(PLACENEXT_SECRETKEY, "")
+    t.Setenv(PLACENEXT_CREDENTIALS_FILE, path)
+    t.Setenv(PLACENEXT_PROFILE, "staging")
+    chain := DefaultCredentialsChain()
+    // the file is used when the variable environment is not available
+    credentials, err := chain.Retrieve(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if credentials.ApiKey != "staging-key" {
+        t.Error("expect staging profile got", credentials.ApiKey)
+    }
+    // variable environment come first
+    t.Setenv(PLACENEXT_APIKEY, apiKey)
+    t.Setenv(PLACENEXT_SECRETKEY, secretKey)
+    if credentials, err = chain.Retrieve(context.Background()); err != nil || credentials.ApiKey != apiKey {
+        t.Error("expect environment credentials got", credentials, err)
+    }
+    // missing profile
+    if _, err = NewFileProvider(path, "missing").Retrieve(context.Background()); !errors.Is(err, ErrNoCredentials) {
+        t.Error("expect no credentials got", err)
+    }
+    // malformed secret stop the chain
+    t.Setenv(PLACENEXT_SECRETKEY, "not base64!")
+    if _, err = chain.Retrieve(context.Background()); err == nil || errors.Is(err, ErrNoCredentials) {
+        t.Error("expect invalid secret key error got", err)
+    }
+}
+
+func TestClientCredentialsProvider(t *testing.T) {
+    var auth string
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        auth = r.Header.Get(Authorization)
+    }))
+    defer server.Close()
+    provider, err := NewStaticProvider("provider-key", secretKey)
+    if err != nil {
+        t.Fatal(err)
+    }
+    config, _ := NewConfig(apiKey, secretKey)
+    client := NewRestClient(config)
+    client.SetCredentialsProvider(provider)
+    req, _ := http.NewRequest("GET", server.URL, nil)
+    resp, err := client.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if apiKey, _, err := ParseAuthorization(auth); err != nil || apiKey != "provider-key" {
+        t.Error("expect request signed with provider credentials got", auth)
+    }
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "net/http"
+)
+
+// idempotencyKey is the context key of the idempotency key
+type idempotencyKey struct{}
+
+// NewIdempotencyKey return a new random idempotency key
+func NewIdempotencyKey() string {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        panic("rest: cannot read random bytes " + err.Error())
+    }
+    return hex.EncodeToString(b)
+}
+
+// WithIdempotencyKey return a copy of ctx that carry the given idempotency key.
+// The Client send the key in X-PlaceNext-Idempotency-Key header, the header is
+// part of the signature and stay the same across retries of the request so the
+// server can discard a duplicate batch. Supply your own key to dedupe a batch
+// across process restarts.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+    return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// IdempotencyKeyFromContext return the idempotency key of the given context or empty string
+func IdempotencyKeyFromContext(ctx context.Context) string {
+    key, _ := ctx.Value(idempotencyKey{}).(string)
+    return key
+}
+
+// EnsureIdempotencyKey return ctx if it already carry an idempotency key otherwise
+// a copy of ctx with a new random key
+func EnsureIdempotencyKey(ctx context.Context) context.Context {
+    if IdempotencyKeyFromContext(ctx) != "" {
+        return ctx
+    }
+    return WithIdempotencyKey(ctx, NewIdempotencyKey())
+}
+
+// setIdempotencyKey add the idempotency key of the request context to the request
+// header unless the header is already set
+func setIdempotencyKey(req *http.Request) {
+    if req.Header.Get(XPlacenextIdempotencyKey) != "" {
+        return
+    }
+    if key := IdempotencyKeyFromContext(req.Context()); key != "" {
+        req.Header.Set(XPlacenextIdempotencyKey, key)
+    }
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "net/http"
+
+    "github.com/aimmatic/aimmatic-go-sdk-placenext/telemetry"
+)
+
+// Exchange send a request and return its response
+type Exchange func(req *http.Request) (*http.Response, error)
+
+// Interceptor wrap an exchange of the client with the api server. It can change the
+// request before calling next, change the response after, or answer without calling
+// next at all. An interceptor is called for every attempt of a request.
+type Interceptor func(req *http.Request, next Exchange) (*http.Response, error)
+
+// Phase tell when an interceptor run relative to the signature of the request
+type Phase int
+
+const (
+    // BeforeSign interceptors run before the request is signed. Header they add,
+    // such as X-Placenext-* header, are covered by the signature.
+    BeforeSign Phase = iota
+    // AfterSign interceptors run after the request is signed, right before it is
+    // sent. Any change they make to the signed part of the request break the signature.
+    AfterSign
+)
+
+// Use append the interceptors to the given phase. Within a phase, interceptors run
+// in the order they were added, the first one being the outermost.
+func (c *Client) Use(phase Phase, interceptors ...Interceptor) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if phase == BeforeSign {
+        c.beforeSign = append(c.beforeSign, interceptors...)
+    } else {
+        c.afterSign = append(c.afterSign, interceptors...)
+    }
+}
+
+// exchange return the chain of interceptors of an attempt signed with the given signing
+func (c *Client) exchange(sign *signing) Exchange {
+    c.mu.RLock()
+    chain := make([]Interceptor, 0, len(c.beforeSign)+len(c.afterSign)+5)
+    if c.breakers != nil {
+        chain = append(chain, breakerInterceptor(c.breakers))
+    }
+    if c.limiters != nil {
+        chain = append(chain, limitInterceptor(c.limiters.For(sign.apiKey)))
+    }
+    if propagator := c.propagator; propagator != nil || c.tracer != nil || c.meter != nil {
+        if propagator == nil && c.tracer != nil {
+            propagator = telemetry.TraceContext{}
+        }
+        chain = append(chain, telemetryInterceptor(propagator, c.meter))
+    }
+    chain = append(chain, c.beforeSign...)
+    chain = append(chain, c.signInterceptor(sign))
+    chain = append(chain, c.afterSign...)
+    if c.logger != nil {
+        chain = append(chain, logInterceptor(c.logger, c.debug))
+    }
+    c.mu.RUnlock()
+    exchange := Exchange(c.Client.Do)
+    for i := len(chain) - 1; i >= 0; i-- {
+        interceptor, next := chain[i], exchange
+        exchange = func(req *http.Request) (*http.Response, error) {
+            return interceptor(req, next)
+        }
+    }
+    return exchange
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "strings"
+)
+
+func TestClientInterceptors(t *testing.T) {
+    secret, _ := GetSecretKeyAsByte(secretKey)
+    server := httptest.NewServer(NewVerifier(StaticKeyStore{apiKey: secret}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Header.Get("X-Placenext-Tenant") != "acme" {
+            t.Error("expect tenant header got", r.Header.Get("X-Placenext-Tenant"))
+        }
+    })))
+    defer server.Close()
+    config, _ := NewConfig(apiKey, secretKey)
+    client := NewRestClient(config)
+    var order []string
+    trace := func(name string) Interceptor {
+        return func(req *http.Request, next Exchange) (*http.Response, error) {
+            order = append(order, name+":"+strconv.FormatBool(req.Header.Get(Authorization) != ""))
+            return next(req)
+        }
+    }
+    client.Use(BeforeSign, func(req *http.Request, next Exchange) (*http.Response, error) {
+        req.Header.Set("X-Placenext-Tenant", "acme")
+        return next(req)
+    }, trace("before"))
+    client.Use(AfterSign, trace("after"))
+    req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/placeNextIngest/PointImport", strings.NewReader(body))
+    req.Header.Set(ContentType, MediaJson)
+    resp, err := client.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        t.Error("expect header added before signing to be signed got", resp.StatusCode)
+    }
+    if strings.Join(order, ",") != "before:false,after:true" {
+        t.Error("wrong interceptor order", order)
+    }
+
+    // a signed header changed after signing break the signature
+    client.Use(AfterSign, func(req *http.Request, next Exchange) (*http.Response, error) {
+        req.Header.Set("X-Placenext-Tenant", "other")
+        return next(req)
+    })
+    req, _ = http.NewRequest(http.MethodPost, server.URL+"/v1/placeNextIngest/PointImport", strings.NewReader(body))
+    req.Header.Set(ContentType, MediaJson)
+    if resp, err = client.Do(req); err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusUnauthorized {
+        t.Error("expect header changed after signing to be rejected got", resp.StatusCode)
+    }
+}
+
+func TestClientInterceptorFault(t *testing.T) {
+    config, _ := NewConfig(apiKey, secretKey)
+    client := NewRestClient(config)
+    fault := errors.New("injected fault")
+    client.Use(BeforeSign, func(req *http.Request, next Exchange) (*http.Response, error) {
+        return nil, fault
+    })
+    req, _ := http.NewRequest(http.MethodGet, "http://api.placenext.invalid/", nil)
+    if _, err := client.Do(req); !errors.Is(err, fault) {
+        t.Error("expect injected fault got", err)
+    }
+    if req.Header.Get(Authorization) != "" {
+        t.Error("request must not be signed when an interceptor answer first")
+    }
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "context"
+    "io"
+    "net/http"
+    "strconv"
+    "sync"
+    "time"
+)
+
+// rate limit response header
+const (
+    XRateLimitRemaining = "X-RateLimit-Remaining"
+    XRateLimitReset     = "X-RateLimit-Reset"
+)
+
+// RateLimit configure a Limiter
+type RateLimit struct {
+    // Rate is the number of requests per second, zero or negative does not limit the rate
+    Rate float64
+    // Burst is the number of requests that can be sent at once, it is at least 1
+    Burst int
+    // MaxInFlight limit the requests waiting for their response or which response body
+    // is not closed yet, zero or negative does not limit them
+    MaxInFlight int
+}
+
+// Limiter is a token bucket and a max in flight limiter of the requests of an api key.
+// It slow down when the api server throttle, that is on 429 Too Many Requests or when
+// X-RateLimit-Remaining reach zero, and recover the configured rate progressively.
+type Limiter struct {
+    limit    RateLimit
+    inFlight chan struct{}
+
+    mu sync.Mutex
+    // current rate, lower than the configured rate after a throttle
+    rate        float64
+    tokens      float64
+    last        time.Time
+    pausedUntil time.Time
+}
+
+// NewLimiter create a limiter of the given limit
+func NewLimiter(limit RateLimit) *Limiter {
+    if limit.Burst < 1 {
+        limit.Burst = 1
+    }
+    l := &Limiter{limit: limit, rate: limit.Rate, tokens: float64(limit.Burst)}
+    if limit.MaxInFlight > 0 {
+        l.inFlight = make(chan struct{}, limit.MaxInFlight)
+    }
+    return l
+}
+
+// Wait block until a request can be sent or ctx is done. The returned function must be
+// called once the request is completed to release its in flight slot.
+func (l *Limiter) Wait(ctx context.Context) (release func(), err error) {
+    if l.inFlight != nil {
+        select {
+        case l.inFlight <- struct{}{}:
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        }
+    }
+    release = l.release
+    for {
+        delay := l.reserve(time.Now())
+        if delay <= 0 {
+            return release, nil
+        }
+        timer := time.NewTimer(delay)
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            release()
+            return nil, ctx.Err()
+        case <-timer.C:
+        }
+    }
+}
+
+// release an in flight slot
+func (l *Limiter) release() {
+    if l.inFlight != nil {
+        <-l.inFlight
+    }
+}
+
+// reserve take a token and return zero or return how long to wait before trying again
+func (l *Limiter) reserve(now time.Time) time.Duration {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if now.Before(l.pausedUntil) {
+        return l.pausedUntil.Sub(now)
+    }
+    if l.limit.Rate <= 0 {
+        return 0
+    }
+    if !l.last.IsZero() {
+        l.tokens += now.Sub(l.last).Seconds() * l.rate
+        if burst := float64(l.limit.Burst); l.tokens > burst {
+            l.tokens = burst
+        }
+    }
+    l.last = now
+    if l.tokens >= 1 {
+        l.tokens--
+        return 0
+    }
+    return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// Observe adapt the limiter to the response of a request. A 429 Too Many Requests halve
+// the rate and pause the requests until Retry-After, a response without remaining quota
+// pause the requests until X-RateLimit-Reset and any other response recover the rate.
+func (l *Limiter) Observe(resp *http.Response) {
+    now := time.Now()
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if resp.StatusCode == http.StatusTooManyRequests {
+        delay, ok := retryAfter(resp)
+        if !ok {
+            delay = time.Second
+        }
+        l.pause(now.Add(delay))
+        if l.rate /= 2; l.rate < l.limit.Rate/16 {
+            l.rate = l.limit.Rate / 16
+        }
+        // the bucket is empty when the server throttle
+        l.tokens = 0
+        return
+    }
+    if remaining, err := strconv.Atoi(resp.Header.Get(XRateLimitRemaining)); err == nil && remaining <= 0 {
+        if reset, ok := rateLimitReset(resp, now); ok {
+            l.pause(reset)
+        }
+    }
+    // recover a tenth of the configured rate per successful response
+    if l.rate < l.limit.Rate {
+        if l.rate += l.limit.Rate / 10; l.rate > l.limit.Rate {
+            l.rate = l.limit.Rate
+        }
+    }
+}
+
+// pause the requests until the given time
+func (l *Limiter) pause(until time.Time) {
+    if until.After(l.pausedUntil) {
+        l.pausedUntil = until
+    }
+}
+
+// rateLimitReset parse X-RateLimit-Reset either seconds until the reset or unix time
+func rateLimitReset(resp *http.Response, now time.Time) (time.Time, bool) {
+    seconds, err := strconv.ParseInt(resp.Header.Get(XRateLimitReset), 10, 64)
+    if err != nil || seconds < 0 {
+        return time.Time{}, false
+    }
+    // a value larger than a year of seconds is a unix time
+    if seconds > 365*24*3600 {
+        return time.Unix(seconds, 0), true
+    }
+    return now.Add(time.Duration(seconds) * time.Second), true
+}
+
+// Limiters hold a Limiter per api key. Share it between clients that use the same
+// credentials so they share the same budget.
+type Limiters struct {
+    limit RateLimit
+
+    mu       sync.Mutex
+    limiters map[string]*Limiter
+}
+
+// NewLimiters create limiters of the given limit
+func NewLimiters(limit RateLimit) *Limiters {
+    return &Limiters{limit: limit, limiters: make(map[string]*Limiter)}
+}
+
+// For return the limiter of the api key
+func (ls *Limiters) For(apiKey string) *Limiter {
+    ls.mu.Lock()
+    defer ls.mu.Unlock()
+    l, ok := ls.limiters[apiKey]
+    if !ok {
+        l = NewLimiter(ls.limit)
+        ls.limiters[apiKey] = l
+    }
+    return l
+}
+
+// WithLimiters limit the requests per api key, see Client.SetLimiters
+func WithLimiters(limiters *Limiters) Option {
+    return func(o *clientOptions) {
+        o.limiters = limiters
+    }
+}
+
+// SetLimiters limit the rate and the in flight requests of the client per api key.
+// Every attempt of a request wait for the limiter of its api key before it is signed.
+// A nil limiters disable limiting.
+func (c *Client) SetLimiters(limiters *Limiters) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.limiters = limiters
+}
+
+// Limiters return the limiters of the client or nil
+func (c *Client) Limiters() *Limiters {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.limiters
+}
+
+// limitInterceptor is the built-in interceptor that wait for the limiter before sending
+// an attempt and adapt the limiter to its response
+func limitInterceptor(limiter *Limiter) Interceptor {
+    return func(req *http.Request, next Exchange) (*http.Response, error) {
+        release, err := limiter.Wait(req.Context())
+        if err != nil {
+            return nil, err
+        }
+        resp, err := next(req)
+        if err != nil {
+            release()
+            return resp, err
+        }
+        limiter.Observe(resp)
+        resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+        return resp, nil
+    }
+}
+
+// releaseBody release the in flight slot of a request once its response body is closed
+type releaseBody struct {
+    io.ReadCloser
+    once    sync.Once
+    release func()
+}
+
+func (b *releaseBody) Close() error {
+    err := b.ReadCloser.Close()
+    b.once.Do(b.release)
+    return err
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+func TestClientLimiters(t *testing.T) {
+    var inFlight, maxInFlight int32
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        n := atomic.AddInt32(&inFlight, 1)
+        defer atomic.AddInt32(&inFlight, -1)
+        for {
+            max := atomic.LoadInt32(&maxInFlight)
+            if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+                break
+            }
+        }
+        time.Sleep(10 * time.Millisecond)
+    }))
+    defer server.Close()
+    config, _ := NewConfig(apiKey, secretKey)
+    limiters := NewLimiters(RateLimit{Rate: 100, Burst: 1, MaxInFlight: 2})
+    // both clients share the budget of the api key
+    clients := []*Client{NewRestClient(config, WithLimiters(limiters)), NewRestClient(config, WithLimiters(limiters))}
+    start := time.Now()
+    var wg sync.WaitGroup
+    for i := 0; i < 10; i++ {
+        wg.Add(1)
+        go func(client *Client) {
+            defer wg.Done()
+            req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+            resp, err := client.Do(req)
+            if err != nil {
+                t.Error(err)
+                return
+            }
+            resp.Body.Close()
+        }(clients[i%2])
+    }
+    wg.Wait()
+    if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+        t.Error("expect 10 requests at 100 per second to take about 90ms got", elapsed)
+    }
+    if maxInFlight > 2 {
+        t.Error("expect at most 2 requests in flight got", maxInFlight)
+    }
+}
+
+func TestLimiterThrottle(t *testing.T) {
+    limiter := NewLimiter(RateLimit{Rate: 1000, Burst: 10})
+    resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"1"}}}
+    limiter.Observe(resp)
+    ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+    defer cancel()
+    if _, err := limiter.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+        t.Error("expect limiter to be paused after 429 got", err)
+    }
+    if limiter.rate != 500 {
+        t.Error("expect rate to be halved got", limiter.rate)
+    }
+    limiter.Observe(&http.Response{StatusCode: http.StatusOK, Header: http.Header{}})
+    if limiter.rate != 600 {
+        t.Error("expect rate to recover got", limiter.rate)
+    }
+
+    limiter = NewLimiter(RateLimit{})
+    resp = &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+    resp.Header.Set(XRateLimitRemaining, "0")
+    resp.Header.Set(XRateLimitReset, "2")
+    limiter.Observe(resp)
+    if delay := limiter.reserve(time.Now()); delay < time.Second {
+        t.Error("expect limiter to be paused until reset got", delay)
+    }
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "context"
+    "log/slog"
+    "net/http"
+    "net/http/httputil"
+    "net/url"
+    "strings"
+    "time"
+)
+
+// Redacted replace the value of a secret header in the logs
+const Redacted = "REDACTED"
+
+// redactedHeaders are never written to the logs
+var redactedHeaders = []string{Authorization, "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// redactedQuery are the query parameters of a presigned url never written to the logs
+var redactedQuery = []string{XPlacenextSignature, XPlacenextApiKey}
+
+// attemptKey is the context key of the attempt number of a request
+type attemptKey struct{}
+
+// withAttempt return a copy of ctx that carry the attempt number
+func withAttempt(ctx context.Context, attempt int) context.Context {
+    return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// AttemptFromContext return the attempt number of the request being sent, starting
+// from 1. It is available to interceptors.
+func AttemptFromContext(ctx context.Context) int {
+    if attempt, ok := ctx.Value(attemptKey{}).(int); ok {
+        return attempt
+    }
+    return 1
+}
+
+// WithLogger log every exchange with the api server to the given logger, see Client.SetLogger
+func WithLogger(logger *slog.Logger) Option {
+    return func(o *clientOptions) {
+        o.logger = logger
+    }
+}
+
+// WithDebug dump the requests and responses to the logger, see Client.SetDebug
+func WithDebug(debug bool) Option {
+    return func(o *clientOptions) {
+        o.debug = debug
+    }
+}
+
+// SetLogger log every exchange with the api server: its method, endpoint, operation,
+// status, latency, request id, attempt and payload size. A nil logger disable logging.
+func (c *Client) SetLogger(logger *slog.Logger) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.logger = logger
+}
+
+// Logger return the logger of the client or nil
+func (c *Client) Logger() *slog.Logger {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.logger
+}
+
+// SetDebug dump the requests and responses include their body to the logger at debug
+// level. The Authorization header and other secrets are always redacted. The bodies are
+// buffered to be dumped so it should not be used with large imports.
+func (c *Client) SetDebug(debug bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.debug = debug
+}
+
+// Endpoint return the url of the request without query string
+func Endpoint(req *http.Request) string {
+    u := *req.URL
+    u.RawQuery, u.Fragment, u.User = "", "", nil
+    return u.String()
+}
+
+// logInterceptor is the built-in interceptor that log the exchange once it is signed
+func logInterceptor(logger *slog.Logger, debug bool) Interceptor {
+    return func(req *http.Request, next Exchange) (*http.Response, error) {
+        ctx := req.Context()
+        attrs := []slog.Attr{
+            slog.String("method", req.Method),
+            slog.String("endpoint", Endpoint(req)),
+            slog.Int("attempt", AttemptFromContext(ctx)),
+            slog.Int64("bytes", req.ContentLength),
+        }
+        if operation := OperationFromContext(ctx); operation != "" {
+            attrs = append(attrs, slog.String("operation", operation))
+        }
+        if debug && logger.Enabled(ctx, slog.LevelDebug) {
+            dumpRequest(ctx, logger, req)
+        }
+        start := time.Now()
+        resp, err := next(req)
+        attrs = append(attrs, slog.Duration("latency", time.Since(start)))
+        if err != nil {
+            logger.LogAttrs(ctx, slog.LevelError, "placenext request failed", append(attrs, slog.String("error", err.Error()))...)
+            return resp, err
+        }
+        attrs = append(attrs, slog.Int("status", resp.StatusCode))
+        if requestId := resp.Header.Get(XRequestId); requestId != "" {
+            attrs = append(attrs, slog.String("requestId", requestId))
+        }
+        level := slog.LevelInfo
+        if resp.StatusCode >= 400 {
+            level = slog.LevelWarn
+        }
+        logger.LogAttrs(ctx, level, "placenext request", attrs...)
+        if debug && logger.Enabled(ctx, slog.LevelDebug) {
+            dumpResponse(ctx, logger, resp)
+        }
+        return resp, err
+    }
+}
+
+// redactHeader return a copy of the header without secret
+func redactHeader(header http.Header) http.Header {
+    header = header.Clone()
+    for _, name := range redactedHeaders {
+        if header.Get(name) != "" {
+            header.Set(name, Redacted)
+        }
+    }
+    return header
+}
+
+// redactURL return a copy of the url without secret in its query, the other parameters
+// are kept as is even if they are malformed
+func redactURL(u *url.URL) *url.URL {
+    redacted := *u
+    if u.RawQuery == "" {
+        return &redacted
+    }
+    params := strings.Split(u.RawQuery, "&")
+    for i, param := range params {
+        key, _, _ := strings.Cut(param, "=")
+        name, err := url.QueryUnescape(key)
+        if err != nil {
+            continue
+        }
+        for _, secret := range redactedQuery {
+            if name == secret {
+                params[i] = key + "=" + Redacted
+            }
+        }
+    }
+    redacted.RawQuery = strings.Join(params, "&")
+    return &redacted
+}
+
+// dumpRequest log the request with its body, the body is replaced so it can be sent
+func dumpRequest(ctx context.Context, logger *slog.Logger, req *http.Request) {
+    redacted := *req
+    redacted.Header = redactHeader(req.Header)
+    redacted.URL = redactURL(req.URL)
+    dump, err := httputil.DumpRequestOut(&redacted, true)
+    // the dump replaced the body of the copy with a buffered one
+    req.Body = redacted.Body
+    if err != nil {
+        logger.LogAttrs(ctx, slog.LevelDebug, "placenext request dump failed", slog.String("error", err.Error()))
+        return
+    }
+    logger.LogAttrs(ctx, slog.LevelDebug, "placenext request dump", slog.String("dump", string(dump)))
+}
+
+// dumpResponse log the response with its body, the body is replaced so it can be read
+func dumpResponse(ctx context.Context, logger *slog.Logger, resp *http.Response) {
+    redacted := *resp
+    redacted.Header = redactHeader(resp.Header)
+    dump, err := httputil.DumpResponse(&redacted, true)
+    resp.Body = redacted.Body
+    if err != nil {
+        logger.LogAttrs(ctx, slog.LevelDebug, "placenext response dump failed", slog.String("error", err.Error()))
+        return
+    }
+    logger.LogAttrs(ctx, slog.LevelDebug, "placenext response dump", slog.String("dump", string(dump)))
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "bytes"
+    "context"
+    "encoding/json"
+    "io/ioutil"
+    "log/slog"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+)
+
+func TestClientLogger(t *testing.T) {
+    var authorization string
+    calls := 0
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        calls++
+        authorization = r.Header.Get(Authorization)
+        if b, _ := ioutil.ReadAll(r.Body); string(b) != body {
+            t.Error("expect body to be sent after the dump got", string(b))
+        }
+        if calls == 1 {
+            w.WriteHeader(http.StatusServiceUnavailable)
+            return
+        }
+        w.Header().Set(XRequestId, "req-42")
+        w.Write([]byte(`{"status":{"code":200}}`))
+    }))
+    defer server.Close()
+    var buf bytes.Buffer
+    logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+    config, _ := NewConfig(apiKey, secretKey)
+    client := NewRestClient(config, WithLogger(logger), WithDebug(true))
+    client.SetRetryPolicy(&RetryPolicy{MaxAttempts: 2})
+    req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/placeNextIngest/PointImport?debug=1", strings.NewReader(body))
+    req.Header.Set(ContentType, MediaJson)
+    resp, err := client.DoContext(WithOperation(req.Context(), "PointImport"), req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if b, _ := ioutil.ReadAll(resp.Body); string(b) != `{"status":{"code":200}}` {
+        t.Error("expect body to be readable after the dump got", string(b))
+    }
+    resp.Body.Close()
+
+    var requests []map[string]interface{}
+    for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+        record := make(map[string]interface{})
+        if err := json.Unmarshal([]byte(line), &record); err != nil {
+            t.Fatal(err)
+        }
+        if record["msg"] == "placenext request" {
+            requests = append(requests, record)
+        }
+    }
+    if len(requests) != 2 {
+        t.Fatal("expect a record per attempt got", len(requests))
+    }
+    last := requests[1]
+    if last["attempt"] != 2.0 || last["status"] != 200.0 || last["requestId"] != "req-42" || last["operation"] != "PointImport" ||
+        last["endpoint"] != server.URL+"/v1/placeNextIngest/PointImport" || last["bytes"] != float64(len(body)) {
+        t.Error("wrong request record", last)
+    }
+    if _, ok := last["latency"]; !ok {
+        t.Error("expect latency in request record")
+    }
+    signature := authorization[strings.LastIndexByte(authorization, ':')+1:]
+    if strings.Contains(buf.String(), signature) || !strings.Contains(buf.String(), "Authorization: "+Redacted) {
+        t.Error("expect authorization to be redacted", buf.String())
+    }
+}
+
+
+func TestDumpRequestRedactQuery(t *testing.T) {
+    var buf bytes.Buffer
+    logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+    req, _ := http.NewRequest(http.MethodPost, "https://api.aimmatic.com/v1/placeNextIngest/GeometryImport?"+
+        "X-Placenext-ApiKey=key-1&X-Placenext-Expires=1136214245&X-Placenext-Signature=c2lnbmF0dXJl&debug=1", strings.NewReader(body))
+    dumpRequest(context.Background(), logger, req)
+    dump := buf.String()
+    if strings.Contains(dump, "key-1") || strings.Contains(dump, "c2lnbmF0dXJl") {
+        t.Error("expect presigned query to be redacted", dump)
+    }
+    if !strings.Contains(dump, "X-Placenext-Signature="+Redacted) || !strings.Contains(dump, "X-Placenext-Expires=1136214245&") || !strings.Contains(dump, "debug=1") {
+        t.Error("expect other query parameters to be kept", dump)
+    }
+    // the request is not modified
+    if req.URL.Query().Get(XPlacenextSignature) != "c2lnbmF0dXJl" {
+        t.Error("expect request url to be unchanged got", req.URL)
+    }
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "crypto/sha256"
+    "crypto/tls"
+    "crypto/x509"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "log/slog"
+    "net"
+    "net/http"
+    "net/url"
+    "time"
+
+    "github.com/aimmatic/aimmatic-go-sdk-placenext/telemetry"
+)
+
+// ErrPublicKeyPin is returned when the server certificate chain does not contain a pinned public key
+var ErrPublicKeyPin = errors.New("server public key is not pinned")
+
+// Option configure a Client created with NewRestClient
+type Option func(o *clientOptions)
+
+// Timeouts bound each phase of an exchange with the api server. A zero value keep
+// the default of http.DefaultTransport.
+type Timeouts struct {
+    // Dial bound the establishment of the tcp connection
+    Dial time.Duration
+    // KeepAlive is the interval of tcp keep-alive probes
+    KeepAlive time.Duration
+    // TLSHandshake bound the tls handshake
+    TLSHandshake time.Duration
+    // ResponseHeader bound the wait for the response header once the request is written
+    ResponseHeader time.Duration
+    // ExpectContinue bound the wait for 100-continue response
+    ExpectContinue time.Duration
+    // IdleConn is how long an idle connection stay in the pool
+    IdleConn time.Duration
+}
+
+// PoolLimits tune the connection pool. A zero value keep the default of http.DefaultTransport.
+type PoolLimits struct {
+    // MaxIdleConns limit the idle connections across all hosts
+    MaxIdleConns int
+    // MaxIdleConnsPerHost limit the idle connections to the api server
+    MaxIdleConnsPerHost int
+    // MaxConnsPerHost limit the connections to the api server include the active ones
+    MaxConnsPerHost int
+}
+
+// clientOptions is the result of the options given to NewRestClient
+type clientOptions struct {
+    httpClient   *http.Client
+    transport    http.RoundTripper
+    timeout      *time.Duration
+    timeouts     *Timeouts
+    pool         *PoolLimits
+    proxy        func(*http.Request) (*url.URL, error)
+    certificates []tls.Certificate
+    rootCAs      *x509.CertPool
+    pins         []string
+    logger       *slog.Logger
+    debug        bool
+    tracer       telemetry.Tracer
+    meter        telemetry.Meter
+    propagator   telemetry.Propagator
+    limiters     *Limiters
+    breakers     *Breakers
+    clock        func() time.Time
+}
+
+// tuned report whether an option need to modify the http.Transport
+func (o *clientOptions) tuned() bool {
+    return o.timeouts != nil || o.pool != nil || o.proxy != nil || len(o.certificates) > 0 || o.rootCAs != nil || len(o.pins) > 0
+}
+
+// WithHTTPClient send the requests with the given http client. The transport options
+// are applied to a copy of its transport which must then be an *http.Transport.
+func WithHTTPClient(client *http.Client) Option {
+    return func(o *clientOptions) {
+        o.httpClient = client
+    }
+}
+
+// WithTransport send the requests with the given RoundTripper. The other transport
+// options are applied to a copy of it which must then be an *http.Transport.
+func WithTransport(transport http.RoundTripper) Option {
+    return func(o *clientOptions) {
+        o.transport = transport
+    }
+}
+
+// WithTimeout set the overall timeout of a request which context has no deadline,
+// see Client.SetTimeout. The default is DefaultTimeout.
+func WithTimeout(timeout time.Duration) Option {
+    return func(o *clientOptions) {
+        o.timeout = &timeout
+    }
+}
+
+// WithTimeouts set the timeout of each phase of an exchange
+func WithTimeouts(timeouts Timeouts) Option {
+    return func(o *clientOptions) {
+        o.timeouts = &timeouts
+    }
+}
+
+// WithPoolLimits set the limits of the connection pool
+func WithPoolLimits(limits PoolLimits) Option {
+    return func(o *clientOptions) {
+        o.pool = &limits
+    }
+}
+
+// WithProxy route the requests through the given http proxy. A nil url disable
+// the proxy of the environment that is used by default.
+func WithProxy(proxy *url.URL) Option {
+    return func(o *clientOptions) {
+        if proxy == nil {
+            o.proxy = func(*http.Request) (*url.URL, error) { return nil, nil }
+        } else {
+            o.proxy = http.ProxyURL(proxy)
+        }
+    }
+}
+
+// WithClientCertificate present the given certificates to the server for mutual tls
+func WithClientCertificate(certificates ...tls.Certificate) Option {
+    return func(o *clientOptions) {
+        o.certificates = append(o.certificates, certificates...)
+    }
+}
+
+// WithRootCAs verify the server certificate with the given pool instead of the system pool
+func WithRootCAs(pool *x509.CertPool) Option {
+    return func(o *clientOptions) {
+        o.rootCAs = pool
+    }
+}
+
+// WithPinnedPublicKeys accept the server only if its certificate chain contain one of
+// the given public keys. A pin is the base64 sha256 of the DER encoded subject public
+// key info, see PublicKeyPin. The certificate chain is still verified and only the
+// certificates of a verified chain are matched against the pins.
+func WithPinnedPublicKeys(pins ...string) Option {
+    return func(o *clientOptions) {
+        o.pins = append(o.pins, pins...)
+    }
+}
+
+// PublicKeyPin return the pin of the certificate public key
+func PublicKeyPin(certificate *x509.Certificate) string {
+    sum := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+    return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// verifyPins return a tls connection check accepting a verified chain that contain a
+// pinned key. The certificates sent by the server are not used as they may not be part
+// of the verified chain.
+func verifyPins(pins []string) func(tls.ConnectionState) error {
+    pinned := make(map[string]bool, len(pins))
+    for _, pin := range pins {
+        pinned[pin] = true
+    }
+    return func(state tls.ConnectionState) error {
+        for _, chain := range state.VerifiedChains {
+            for _, certificate := range chain {
+                if pinned[PublicKeyPin(certificate)] {
+                    return nil
+                }
+            }
+        }
+        return fmt.Errorf("%w: %s", ErrPublicKeyPin, state.ServerName)
+    }
+}
+
+// newHTTPClient build the http client of the options
+func (o *clientOptions) newHTTPClient() (*http.Client, error) {
+    client := &http.Client{}
+    if o.httpClient != nil {
+        copied := *o.httpClient
+        client = &copied
+    }
+    switch {
+    case o.transport != nil:
+        client.Transport = o.transport
+    case client.Transport == nil:
+        client.Transport = http.DefaultTransport
+    }
+    if !o.tuned() {
+        return client, nil
+    }
+    base, ok := client.Transport.(*http.Transport)
+    if !ok {
+        return nil, fmt.Errorf("transport options require an *http.Transport, got %T", client.Transport)
+    }
+    transport := base.Clone()
+    if t := o.timeouts; t != nil {
+        dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+        if t.Dial > 0 {
+            dialer.Timeout = t.Dial
+        }
+        if t.KeepAlive != 0 {
+            dialer.KeepAlive = t.KeepAlive
+        }
+        transport.DialContext = dialer.DialContext
+        if t.TLSHandshake > 0 {
+            transport.TLSHandshakeTimeout = t.TLSHandshake
+        }
+        if t.ResponseHeader > 0 {
+            transport.ResponseHeaderTimeout = t.ResponseHeader
+        }
+        if t.ExpectContinue > 0 {
+            transport.ExpectContinueTimeout = t.ExpectContinue
+        }
+        if t.IdleConn > 0 {
+            transport.IdleConnTimeout = t.IdleConn
+        }
+    }
+    if p := o.pool; p != nil {
+        if p.MaxIdleConns > 0 {
+            transport.MaxIdleConns = p.MaxIdleConns
+        }
+        if p.MaxIdleConnsPerHost > 0 {
+            transport.MaxIdleConnsPerHost = p.MaxIdleConnsPerHost
+        }
+        if p.MaxConnsPerHost > 0 {
+            transport.MaxConnsPerHost = p.MaxConnsPerHost
+        }
+    }
+    if o.proxy != nil {
+        transport.Proxy = o.proxy
+    }
+    if len(o.certificates) > 0 || o.rootCAs != nil || len(o.pins) > 0 {
+        if transport.TLSClientConfig == nil {
+            transport.TLSClientConfig = &tls.Config{}
+        }
+        if len(o.certificates) > 0 {
+            transport.TLSClientConfig.Certificates = o.certificates
+        }
+        if o.rootCAs != nil {
+            transport.TLSClientConfig.RootCAs = o.rootCAs
+        }
+        if len(o.pins) > 0 {
+            transport.TLSClientConfig.VerifyConnection = verifyPins(o.pins)
+        }
+    }
+    client.Transport = transport
+    return client, nil
+}
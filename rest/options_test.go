@@ -0,0 +1,174 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/tls"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "errors"
+    "math/big"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "time"
+)
+
+// roundTripFunc is a RoundTripper of a function
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+    return f(req)
+}
+
+func TestClientPinnedPublicKeys(t *testing.T) {
+    server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusOK)
+    }))
+    defer server.Close()
+    roots := x509.NewCertPool()
+    roots.AddCert(server.Certificate())
+    config, _ := NewConfig(apiKey, secretKey)
+
+    client := NewRestClient(config, WithRootCAs(roots), WithPinnedPublicKeys(PublicKeyPin(server.Certificate())))
+    req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+    resp, err := client.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+
+    client = NewRestClient(config, WithRootCAs(roots), WithPinnedPublicKeys("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="))
+    req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+    if _, err = client.Do(req); !errors.Is(err, ErrPublicKeyPin) {
+        t.Error("expect public key pin error got", err)
+    }
+}
+
+// newCertificate create a certificate signed by parent or self signed if parent is nil
+func newCertificate(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    template.NotBefore, template.NotAfter = time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+    if parent == nil {
+        parent, parentKey = template, key
+    }
+    der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+    if err != nil {
+        t.Fatal(err)
+    }
+    certificate, err := x509.ParseCertificate(der)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return certificate, key
+}
+
+func TestClientPinnedPublicKeysUnverified(t *testing.T) {
+    // a leaf of a trusted ca that is not pinned, the pinned certificate of the real
+    // server is only appended to the chain sent by the server
+    ca, caKey := newCertificate(t, &x509.Certificate{
+        SerialNumber:          big.NewInt(1),
+        Subject:               pkix.Name{CommonName: "trusted ca"},
+        IsCA:                  true,
+        BasicConstraintsValid: true,
+        KeyUsage:              x509.KeyUsageCertSign,
+    }, nil, nil)
+    leaf, leafKey := newCertificate(t, &x509.Certificate{
+        SerialNumber: big.NewInt(2),
+        Subject:      pkix.Name{CommonName: "127.0.0.1"},
+        IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+        KeyUsage:     x509.KeyUsageDigitalSignature,
+        ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+    }, ca, caKey)
+    pinned, _ := newCertificate(t, &x509.Certificate{
+        SerialNumber: big.NewInt(3),
+        Subject:      pkix.Name{CommonName: "real server"},
+    }, nil, nil)
+    server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusOK)
+    }))
+    server.TLS = &tls.Config{Certificates: []tls.Certificate{{
+        Certificate: [][]byte{leaf.Raw, pinned.Raw},
+        PrivateKey:  leafKey,
+    }}}
+    server.StartTLS()
+    defer server.Close()
+    roots := x509.NewCertPool()
+    roots.AddCert(ca)
+    config, _ := NewConfig(apiKey, secretKey)
+
+    client := NewRestClient(config, WithRootCAs(roots), WithPinnedPublicKeys(PublicKeyPin(pinned)))
+    req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+    if _, err := client.Do(req); !errors.Is(err, ErrPublicKeyPin) {
+        t.Error("expect public key pin error got", err)
+    }
+    // the pinned ca of the verified chain is accepted
+    client = NewRestClient(config, WithRootCAs(roots), WithPinnedPublicKeys(PublicKeyPin(ca)))
+    req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+    resp, err := client.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+}
+
+func TestClientProxy(t *testing.T) {
+    var target string
+    proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        target = r.URL.String()
+        w.WriteHeader(http.StatusOK)
+    }))
+    defer proxy.Close()
+    proxyURL, _ := url.Parse(proxy.URL)
+    config, _ := NewConfig(apiKey, secretKey)
+    client := NewRestClient(config, WithProxy(proxyURL), WithPoolLimits(PoolLimits{MaxConnsPerHost: 2}))
+    req, _ := http.NewRequest(http.MethodGet, "http://api.placenext.invalid/v1/insights/nss", nil)
+    resp, err := client.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if target != "http://api.placenext.invalid/v1/insights/nss" {
+        t.Error("request was not sent through the proxy", target)
+    }
+}
+
+func TestClientTransportOptions(t *testing.T) {
+    config, _ := NewConfig(apiKey, secretKey)
+    called := false
+    transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+        called = true
+        return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+    })
+    req, _ := http.NewRequest(http.MethodGet, "http://api.placenext.invalid/", nil)
+    resp, err := NewRestClient(config, WithTransport(transport)).Do(req)
+    if err != nil || !called {
+        t.Fatal("custom transport was not used", err)
+    }
+    resp.Body.Close()
+    // tuning a transport that is not an *http.Transport is reported on every request
+    client := NewRestClient(config, WithTransport(transport), WithTimeouts(Timeouts{ResponseHeader: 1}))
+    req, _ = http.NewRequest(http.MethodGet, "http://api.placenext.invalid/", nil)
+    if _, err = client.Do(req); err == nil {
+        t.Error("expect transport option error")
+    }
+}
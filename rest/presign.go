@@ -0,0 +1,207 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "bytes"
+    "context"
+    "crypto/hmac"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strconv"
+    "time"
+)
+
+// query parameters of a presigned url
+const (
+    XPlacenextApiKey        = "X-Placenext-ApiKey"
+    XPlacenextExpires       = "X-Placenext-Expires"
+    XPlacenextSignature     = "X-Placenext-Signature"
+    XPlacenextContentType   = "X-Placenext-Content-Type"
+    XPlacenextContentDigest = "X-Placenext-Content-Digest"
+)
+
+// MaxPresignExpiry is the longest validity of a presigned url
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+// presigned url errors
+var (
+    ErrPresignExpiry       = fmt.Errorf("presigned url expiry must be positive and at most %s", MaxPresignExpiry)
+    ErrPresignExpired      = errors.New("presigned url is expired")
+    ErrContentTypeMismatch = errors.New("content-type does not match the presigned url")
+)
+
+// PresignOption bind a presigned url to the request it can be used for
+type PresignOption func(o *presignOptions)
+
+type presignOptions struct {
+    contentType   string
+    contentDigest string
+}
+
+// WithPresignContentType bind the presigned url to the Content-Type header of the request
+func WithPresignContentType(contentType string) PresignOption {
+    return func(o *presignOptions) {
+        o.contentType = contentType
+    }
+}
+
+// WithPresignBodyDigest bind the presigned url to a body of the given sha-256 digest
+func WithPresignBodyDigest(sha256sum []byte) PresignOption {
+    return func(o *presignOptions) {
+        o.contentDigest = ContentDigestSHA256(sha256sum)
+    }
+}
+
+// StringToSignPresigned build the canonical string of a presigned url. It is made of
+// the lines: PRESIGN, method, expiry unix time and the canonical url which query
+// include the api key, the expiry and the bound content type and digest but not the
+// signature.
+func StringToSignPresigned(method string, expires int64, canonicalURL string) []byte {
+    buf := bytes.NewBuffer(nil)
+    buf.WriteString("PRESIGN\n")
+    buf.WriteString(method)
+    buf.WriteByte('\n')
+    buf.WriteString(strconv.FormatInt(expires, 10))
+    buf.WriteByte('\n')
+    buf.WriteString(canonicalURL)
+    return buf.Bytes()
+}
+
+// PresignURL return rawURL with the api key, the expiry and the signature in its query
+// so a third party can send the request with the given method until expiry without
+// knowing the secret key. The signature is reusable until it expires.
+func PresignURL(ctx context.Context, method, rawURL, apiKey string, signer Signer, expiry time.Time, opts ...PresignOption) (string, error) {
+    u, err := url.Parse(rawURL)
+    if err != nil {
+        return "", err
+    }
+    if u.Scheme == "" || u.Host == "" {
+        return "", fmt.Errorf("presign %s: url must be absolute", rawURL)
+    }
+    o := &presignOptions{}
+    for _, opt := range opts {
+        opt(o)
+    }
+    query, err := parseQuery(u.RawQuery)
+    if err != nil {
+        return "", err
+    }
+    query.Set(XPlacenextApiKey, apiKey)
+    query.Set(XPlacenextExpires, strconv.FormatInt(expiry.Unix(), 10))
+    if o.contentType != "" {
+        query.Set(XPlacenextContentType, o.contentType)
+    }
+    if o.contentDigest != "" {
+        query.Set(XPlacenextContentDigest, o.contentDigest)
+    }
+    query.Del(XPlacenextSignature)
+    u.RawQuery = query.Encode()
+    canonical, err := canonicalURLOf(u.Scheme, u.Host, u.EscapedPath(), u.RawQuery)
+    if err != nil {
+        return "", err
+    }
+    signature, err := signer.Sign(ctx, StringToSignPresigned(method, expiry.Unix(), canonical))
+    if err != nil {
+        return "", err
+    }
+    query.Set(XPlacenextSignature, base64.RawURLEncoding.EncodeToString(signature))
+    u.RawQuery = query.Encode()
+    return u.String(), nil
+}
+
+// Presign return a presigned url of the request with the given method valid for the
+// given duration, see PresignURL. It is signed with the client credentials and clock.
+func (c *Client) Presign(ctx context.Context, method, rawURL string, expires time.Duration, opts ...PresignOption) (string, error) {
+    if expires <= 0 || expires > MaxPresignExpiry {
+        return "", ErrPresignExpiry
+    }
+    sign, err := c.resolveSigning(ctx)
+    if err != nil {
+        return "", err
+    }
+    expiry := c.localNow().Add(c.ClockOffset()).Add(expires)
+    return PresignURL(ctx, method, rawURL, sign.apiKey, sign.signer, expiry, opts...)
+}
+
+// VerifyPresigned authenticate a request sent to a presigned url and return its api key.
+// The bound content type and body digest are checked, the body is replaced so it can
+// be read again by the next handler. A body larger than MaxBodySize is rejected before
+// it is hashed.
+func (v *Verifier) VerifyPresigned(r *http.Request) (string, error) {
+    query, err := parseQuery(r.URL.RawQuery)
+    if err != nil {
+        return "", err
+    }
+    apiKey, encoded := query.Get(XPlacenextApiKey), query.Get(XPlacenextSignature)
+    if apiKey == "" || encoded == "" {
+        return "", ErrMissingAuthorization
+    }
+    requestSignature, err := base64.RawURLEncoding.DecodeString(encoded)
+    if err != nil {
+        return "", ErrMalformedAuthorization
+    }
+    expires, err := strconv.ParseInt(query.Get(XPlacenextExpires), 10, 64)
+    if err != nil {
+        return "", ErrMalformedAuthorization
+    }
+    now := time.Now()
+    if v.Now != nil {
+        now = v.Now()
+    }
+    expiry := time.Unix(expires, 0)
+    if now.After(expiry) {
+        return "", ErrPresignExpired
+    }
+    if expiry.Sub(now) > MaxPresignExpiry+v.maxSkew() {
+        return "", ErrPresignExpiry
+    }
+    secret, err := v.Keys.SecretKey(r.Context(), apiKey)
+    if err != nil {
+        return "", err
+    }
+    query.Del(XPlacenextSignature)
+    canonical, err := canonicalURLOf(requestScheme(r), requestHost(r), r.URL.EscapedPath(), query.Encode())
+    if err != nil {
+        return "", err
+    }
+    signature, _ := NewHMACSigner(secret).Sign(r.Context(), StringToSignPresigned(r.Method, expires, canonical))
+    if !hmac.Equal(signature, requestSignature) {
+        return "", ErrSignatureMismatch
+    }
+    if contentType := query.Get(XPlacenextContentType); contentType != "" && r.Header.Get(ContentType) != contentType {
+        return "", ErrContentTypeMismatch
+    }
+    if contentDigest := query.Get(XPlacenextContentDigest); contentDigest != "" {
+        if err = v.readBody(r); err != nil {
+            return "", err
+        }
+        if sum := ComputeBodySHA256(r); sum == nil || ContentDigestSHA256(sum) != contentDigest {
+            return "", ErrContentDigestMismatch
+        }
+    }
+    return apiKey, nil
+}
+
+// PresignedMiddleware return a handler that verify the presigned url of the request
+// before calling next. The authenticated api key is available with APIKeyFromContext.
+func (v *Verifier) PresignedMiddleware(next http.Handler) http.Handler {
+    return v.middleware(v.VerifyPresigned, next)
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "context"
+    "crypto/sha256"
+    "errors"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "sync/atomic"
+    "time"
+)
+
+func TestPresignedURL(t *testing.T) {
+    secret, _ := GetSecretKeyAsByte(secretKey)
+    verifier := NewVerifier(StaticKeyStore{apiKey: secret})
+    // the server clock is read by the server goroutine
+    var offset atomic.Int64
+    verifier.Now = func() time.Time { return time.Now().Add(time.Duration(offset.Load())) }
+    server := httptest.NewServer(verifier.PresignedMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if key, ok := APIKeyFromContext(r.Context()); !ok || key != apiKey {
+            t.Error("expect authenticated api key got", key)
+        }
+        if b, _ := ioutil.ReadAll(r.Body); string(b) != body {
+            t.Error("expect body to be readable got", string(b))
+        }
+    })))
+    defer server.Close()
+    config, _ := NewConfig(apiKey, secretKey)
+    client := NewRestClient(config)
+    sum := sha256.Sum256([]byte(body))
+    endpoint := server.URL + "/v1/placeNextIngest/GeometryImport"
+    presigned, err := client.Presign(context.Background(), "POST", endpoint, time.Hour, WithPresignContentType(MediaGeoJson), WithPresignBodyDigest(sum[:]))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if strings.Contains(presigned, secretKey) {
+        t.Error("expect the secret key not to be in the url")
+    }
+    // the upload is a plain request that is not signed
+    chunked := false
+    upload := func(method, u, contentType, payload string) (int, string) {
+        req, _ := http.NewRequest(method, u, strings.NewReader(payload))
+        if chunked {
+            req.Body, req.ContentLength = ioutil.NopCloser(strings.NewReader(payload)), -1
+        }
+        req.Header.Set(ContentType, contentType)
+        resp, err := http.DefaultClient.Do(req)
+        if err != nil {
+            t.Fatal(err)
+        }
+        defer resp.Body.Close()
+        msg, _ := ioutil.ReadAll(resp.Body)
+        return resp.StatusCode, strings.TrimSpace(string(msg))
+    }
+    if code, msg := upload("POST", presigned, MediaGeoJson, body); code != http.StatusOK {
+        t.Error("expect valid upload got", code, msg)
+    }
+    // the url can be used until it expires
+    if code, msg := upload("POST", presigned, MediaGeoJson, body); code != http.StatusOK {
+        t.Error("expect second upload got", code, msg)
+    }
+    if _, msg := upload("POST", presigned, MediaJson, body); msg != ErrContentTypeMismatch.Error() {
+        t.Error("expect content type mismatch got", msg)
+    }
+    if _, msg := upload("POST", presigned, MediaGeoJson, strings.ToUpper(body)); msg != ErrContentDigestMismatch.Error() {
+        t.Error("expect content digest mismatch got", msg)
+    }
+    if _, msg := upload("PUT", presigned, MediaGeoJson, body); msg != ErrSignatureMismatch.Error() {
+        t.Error("expect method to be signed got", msg)
+    }
+    // chunked body
+    chunked = true
+    if code, msg := upload("POST", presigned, MediaGeoJson, body); code != http.StatusOK {
+        t.Error("expect chunked upload got", code, msg)
+    }
+    if _, msg := upload("POST", presigned, MediaGeoJson, "forged body"); msg != ErrContentDigestMismatch.Error() {
+        t.Error("expect chunked content digest mismatch got", msg)
+    }
+    chunked = false
+    // body larger than the limit
+    verifier.MaxBodySize = int64(len(body)) - 1
+    if code, msg := upload("POST", presigned, MediaGeoJson, body); code != http.StatusRequestEntityTooLarge || msg != ErrBodyTooLarge.Error() {
+        t.Error("expect body too large got", code, msg)
+    }
+    verifier.MaxBodySize = 0
+    // tampered query
+    tamper := func(key, value string) string {
+        u, _ := url.Parse(presigned)
+        query := u.Query()
+        if value == "" {
+            query.Del(key)
+        } else {
+            query.Set(key, value)
+        }
+        u.RawQuery = query.Encode()
+        return u.String()
+    }
+    if _, msg := upload("POST", tamper(XPlacenextExpires, "9999999999"), MediaGeoJson, body); msg != ErrPresignExpiry.Error() {
+        t.Error("expect expiry out of range got", msg)
+    }
+    if _, msg := upload("POST", tamper(XPlacenextContentDigest, ""), MediaGeoJson, body); msg != ErrSignatureMismatch.Error() {
+        t.Error("expect unbound digest to be rejected got", msg)
+    }
+    if _, msg := upload("POST", tamper(XPlacenextSignature, ""), MediaGeoJson, body); msg != ErrMissingAuthorization.Error() {
+        t.Error("expect missing signature got", msg)
+    }
+    if _, msg := upload("POST", presigned+"&a=1;b=2", MediaGeoJson, body); !strings.HasPrefix(msg, ErrMalformedQuery.Error()) {
+        t.Error("expect malformed query got", msg)
+    }
+    // expired
+    offset.Store(int64(2 * time.Hour))
+    if _, msg := upload("POST", presigned, MediaGeoJson, body); msg != ErrPresignExpired.Error() {
+        t.Error("expect expired url got", msg)
+    }
+    if _, err = client.Presign(context.Background(), "POST", endpoint, MaxPresignExpiry+time.Second); err != ErrPresignExpiry {
+        t.Error("expect expiry out of range got", err)
+    }
+    if _, err = client.Presign(context.Background(), "POST", endpoint+"?a=%zz", time.Hour); !errors.Is(err, ErrMalformedQuery) {
+        t.Error("expect malformed query got", err)
+    }
+}
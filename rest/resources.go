@@ -30,6 +30,15 @@ const (
     // by default if PLACENEXT_ADDRESS is not available from variable environment, the default
     // address api.aimmatic.com is used.
     PLACENEXT_ADDRESS = "PLACENEXT_ADDRESS"
+    // variable environment to provide the path of the shared credentials file.
+    // by default the file ~/.placenext/credentials is used.
+    PLACENEXT_CREDENTIALS_FILE = "PLACENEXT_CREDENTIALS_FILE"
+    // variable environment to provide the profile of the shared credentials file
+    // to use. by default the profile default is used.
+    PLACENEXT_PROFILE = "PLACENEXT_PROFILE"
+
+    // signature scheme of the default config, v1 or v2. By default, v1 is used.
+    PLACENEXT_SIGNATURE_SCHEME = "PLACENEXT_SIGNATURE_SCHEME"
 )
 
 // placenext endpoint
@@ -41,13 +50,16 @@ const (
 
 // required http header to be include in rest api request
 const (
-    Authorization   = "Authorization"
-    ContentType     = "Content-Type"
-    ContentMD5      = "Content-MD5"
-    Date            = "Date"
-    UserAgent       = "User-Agent"
-    XPlacenextDate  = "X-PlaceNext-Date"
-    XForwardedProto = "X-Forwarded-Proto"
+    Authorization            = "Authorization"
+    ContentType              = "Content-Type"
+    ContentMD5               = "Content-MD5"
+    Date                     = "Date"
+    UserAgent                = "User-Agent"
+    XPlacenextDate           = "X-PlaceNext-Date"
+    XForwardedProto          = "X-Forwarded-Proto"
+    XPlacenextIdempotencyKey = "X-PlaceNext-Idempotency-Key"
+    XRequestId               = "X-Request-Id"
+    ContentDigest            = "Content-Digest"
 )
 
 // Media content type
@@ -0,0 +1,240 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "io"
+    "io/ioutil"
+    "math/rand"
+    "net"
+    "net/http"
+    "strconv"
+    "syscall"
+    "time"
+)
+
+// RetryPolicy control how a Client retry a failed request. Every attempt is sent
+// with a fresh date and signature. The request body is buffered when it cannot be
+// replayed with http.Request.GetBody.
+type RetryPolicy struct {
+    // MaxAttempts is the maximum number of attempts include the first one.
+    // A value lower than 2 disable retry.
+    MaxAttempts int
+    // MaxElapsedTime stop retrying once the time since the first attempt exceed it.
+    // Zero means no limit other than MaxAttempts and the request context.
+    MaxElapsedTime time.Duration
+    // InitialBackoff is the delay before the first retry
+    InitialBackoff time.Duration
+    // MaxBackoff cap the delay between two attempts. It does not cap Retry-After.
+    MaxBackoff time.Duration
+    // Multiplier grow the backoff after each attempt
+    Multiplier float64
+    // Jitter randomize the backoff by the given fraction between 0 and 1
+    Jitter float64
+    // RetryableError report whether a transport error should be retried.
+    // If nil, IsRetryableError is used.
+    RetryableError func(err error) bool
+    // RetryableStatus report whether a response should be retried.
+    // If nil, IsRetryableStatus is used.
+    RetryableStatus func(resp *http.Response) bool
+}
+
+// DefaultRetryPolicy is a reasonable policy for the placenext api server
+var DefaultRetryPolicy = RetryPolicy{
+    MaxAttempts:    4,
+    MaxElapsedTime: 2 * time.Minute,
+    InitialBackoff: 200 * time.Millisecond,
+    MaxBackoff:     10 * time.Second,
+    Multiplier:     2,
+    Jitter:         0.2,
+}
+
+// IsRetryableError report whether the transport error is transient such as
+// a timeout or a connection reset. Context cancellation is never retryable.
+func IsRetryableError(err error) bool {
+    if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+        return false
+    }
+    if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+        errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+        return true
+    }
+    var netErr net.Error
+    return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsRetryableStatus report whether the response status is transient, that is
+// 429 Too Many Requests and 5xx except 501 Not Implemented and 505 HTTP Version Not Supported
+func IsRetryableStatus(resp *http.Response) bool {
+    switch resp.StatusCode {
+    case http.StatusTooManyRequests:
+        return true
+    case http.StatusNotImplemented, http.StatusHTTPVersionNotSupported:
+        return false
+    }
+    return resp.StatusCode >= 500
+}
+
+// SetRetryPolicy set the retry policy of the client. A nil policy disable retry.
+func (c *Client) SetRetryPolicy(policy *RetryPolicy) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.retryPolicy = policy
+}
+
+// RetryPolicy return the retry policy of the client or nil if retry is disabled
+func (c *Client) RetryPolicy() *RetryPolicy {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.retryPolicy
+}
+
+// backoff return the delay before the given retry, retry start from 1
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+    delay := float64(p.InitialBackoff)
+    multiplier := p.Multiplier
+    if multiplier < 1 {
+        multiplier = 1
+    }
+    for i := 1; i < retry; i++ {
+        delay *= multiplier
+        if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+            break
+        }
+    }
+    if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+        delay = float64(p.MaxBackoff)
+    }
+    if p.Jitter > 0 {
+        delay -= delay * p.Jitter * rand.Float64()
+    }
+    return time.Duration(delay)
+}
+
+// shouldRetry report whether the result of an attempt should be retried
+func (p *RetryPolicy) shouldRetry(resp *http.Response, err error) bool {
+    if err != nil {
+        if p.RetryableError != nil {
+            return p.RetryableError(err)
+        }
+        return IsRetryableError(err)
+    }
+    if p.RetryableStatus != nil {
+        return p.RetryableStatus(resp)
+    }
+    return IsRetryableStatus(resp)
+}
+
+// retryAfter parse Retry-After header of the response either delay seconds or http date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+    if resp == nil {
+        return 0, false
+    }
+    value := resp.Header.Get("Retry-After")
+    if value == "" {
+        return 0, false
+    }
+    if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+        return time.Duration(seconds) * time.Second, true
+    }
+    if date, err := http.ParseTime(value); err == nil {
+        if delay := time.Until(date); delay > 0 {
+            return delay, true
+        }
+        return 0, true
+    }
+    return 0, false
+}
+
+// replayable make sure the request body can be read again for every attempt
+func replayable(req *http.Request) error {
+    if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+        return nil
+    }
+    buf, err := ioutil.ReadAll(req.Body)
+    req.Body.Close()
+    if err != nil {
+        return err
+    }
+    req.GetBody = func() (io.ReadCloser, error) {
+        return ioutil.NopCloser(bytes.NewReader(buf)), nil
+    }
+    req.Body, _ = req.GetBody()
+    req.ContentLength = int64(len(buf))
+    return nil
+}
+
+// send the request with retry according to the client retry policy. Each attempt
+// is a copy of the request that go through the interceptors and is signed with a
+// fresh date.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+    policy := c.RetryPolicy()
+    // credentials are resolved once so every attempt is signed with the same key
+    // unless the key expire while retrying
+    sign, err := c.resolveSigning(req.Context())
+    if err != nil {
+        return nil, err
+    }
+    if policy == nil || policy.MaxAttempts < 2 {
+        return c.exchange(sign)(req)
+    }
+    if err := replayable(req); err != nil {
+        return nil, err
+    }
+    ctx := req.Context()
+    start := time.Now()
+    for attempt := 1; ; attempt++ {
+        attemptReq := req.Clone(withAttempt(ctx, attempt))
+        if req.GetBody != nil {
+            body, err := req.GetBody()
+            if err != nil {
+                return nil, err
+            }
+            attemptReq.Body = body
+        }
+        if attempt > 1 && sign.expired() {
+            if sign, err = c.resolveSigning(ctx); err != nil {
+                return nil, err
+            }
+        }
+        resp, err := c.exchange(sign)(attemptReq)
+        if attempt >= policy.MaxAttempts || !policy.shouldRetry(resp, err) {
+            return resp, err
+        }
+        delay := policy.backoff(attempt)
+        if after, ok := retryAfter(resp); ok {
+            delay = after
+        }
+        if policy.MaxElapsedTime > 0 && time.Since(start)+delay > policy.MaxElapsedTime {
+            return resp, err
+        }
+        if resp != nil {
+            io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+            resp.Body.Close()
+        }
+        timer := time.NewTimer(delay)
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            return nil, ctx.Err()
+        case <-timer.C:
+        }
+    }
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "context"
+    "testing"
+    "net/http"
+    "net/http/httptest"
+    "io/ioutil"
+    "strings"
+    "time"
+)
+
+func TestClientRetry(t *testing.T) {
+    var attempts int
+    var signatures []string
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        attempts++
+        b, _ := ioutil.ReadAll(r.Body)
+        if string(b) != body {
+            t.Error("attempt", attempts, "got body", string(b))
+        }
+        signatures = append(signatures, r.Header.Get(Authorization))
+        switch attempts {
+        case 1:
+            w.WriteHeader(http.StatusServiceUnavailable)
+        case 2:
+            w.Header().Set("Retry-After", "0")
+            w.WriteHeader(http.StatusTooManyRequests)
+        }
+    }))
+    defer server.Close()
+    config, _ := NewConfig(apiKey, secretKey)
+    client := NewRestClient(config)
+    client.SetRetryPolicy(&RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+    // body that does not provide GetBody must be buffered
+    req, _ := http.NewRequest("POST", server.URL, ioutil.NopCloser(strings.NewReader(body)))
+    req.ContentLength = int64(len(body))
+    resp, err := client.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK || attempts != 3 {
+        t.Error("expect success after 3 attempts got", resp.StatusCode, "after", attempts)
+    }
+    for _, signature := range signatures {
+        if !strings.HasPrefix(signature, "AimMatic "+apiKey+":") {
+            t.Error("attempt was not signed", signature)
+        }
+    }
+    // custom retryable status
+    attempts = 0
+    client.SetRetryPolicy(&RetryPolicy{
+        MaxAttempts:     3,
+        InitialBackoff:  time.Millisecond,
+        RetryableStatus: func(resp *http.Response) bool { return false },
+    })
+    req, _ = http.NewRequest("POST", server.URL, strings.NewReader(body))
+    if resp, err = client.Do(req); err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusServiceUnavailable || attempts != 1 {
+        t.Error("expect no retry got", resp.StatusCode, "after", attempts)
+    }
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+    policy := &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+    expects := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+    for i, expect := range expects {
+        if delay := policy.backoff(i + 1); delay != expect {
+            t.Error("retry", i+1, "expect", expect, "got", delay)
+        }
+    }
+    policy.Jitter = 0.5
+    for i := 0; i < 100; i++ {
+        if delay := policy.backoff(1); delay < 50*time.Millisecond || delay > 100*time.Millisecond {
+            t.Fatal("jitter out of range", delay)
+        }
+    }
+}
+
+func TestClientRetryIdempotencyKey(t *testing.T) {
+    var keys []string
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        keys = append(keys, r.Header.Get(XPlacenextIdempotencyKey))
+        if !strings.Contains(concatenateHeader(r), "x-placenext-idempotency-key:"+r.Header.Get(XPlacenextIdempotencyKey)) {
+            t.Error("idempotency key is not signed")
+        }
+        if len(keys) == 1 {
+            w.WriteHeader(http.StatusBadGateway)
+        }
+    }))
+    defer server.Close()
+    config, _ := NewConfig(apiKey, secretKey)
+    client := NewRestClient(config)
+    client.SetRetryPolicy(&RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})
+    ctx := WithIdempotencyKey(context.Background(), "batch-1")
+    req, _ := http.NewRequestWithContext(ctx, "POST", server.URL, strings.NewReader(body))
+    resp, err := client.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if len(keys) != 2 || keys[0] != "batch-1" || keys[1] != "batch-1" {
+        t.Error("expect the same idempotency key on every attempt got", keys)
+    }
+    if ctx := EnsureIdempotencyKey(context.Background()); len(IdempotencyKeyFromContext(ctx)) != 32 {
+        t.Error("expect a generated idempotency key")
+    }
+}
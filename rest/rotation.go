@@ -0,0 +1,116 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "sync"
+    "time"
+)
+
+// DefaultGracePeriod is how long the previous credentials stay usable after a rotation
+const DefaultGracePeriod = time.Minute
+
+// expirer is implemented by a CredentialsProvider whose credentials can expire.
+// A Client signing the attempts of a request with expired credentials resolve
+// new credentials before the next attempt.
+type expirer interface {
+    Expired(credentials *Credentials) bool
+}
+
+// RotatingProvider is a CredentialsProvider whose credentials can be swapped while
+// requests are in flight. After a rotation the previous credentials stay valid for
+// a grace period so the retries of a request signed with them can drain.
+type RotatingProvider struct {
+    // GracePeriod is how long the previous credentials stay valid after a rotation
+    GracePeriod time.Duration
+
+    mu       sync.RWMutex
+    current  *Credentials
+    previous *Credentials
+    // the previous credentials expire at this time
+    expiry time.Time
+}
+
+// NewRotatingProvider create a provider starting with the given credentials
+func NewRotatingProvider(initial *Credentials) *RotatingProvider {
+    return &RotatingProvider{GracePeriod: DefaultGracePeriod, current: initial}
+}
+
+// Retrieve return the current credentials
+func (p *RotatingProvider) Retrieve(ctx context.Context) (*Credentials, error) {
+    p.mu.RLock()
+    defer p.mu.RUnlock()
+    if p.current == nil {
+        return nil, ErrNoCredentials
+    }
+    return p.current, nil
+}
+
+// Rotate replace the current credentials. The replaced credentials stay valid for the
+// grace period. Rotating to the same credentials does nothing.
+func (p *RotatingProvider) Rotate(credentials *Credentials) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if sameCredentials(p.current, credentials) {
+        return
+    }
+    p.previous, p.current = p.current, credentials
+    p.expiry = time.Now().Add(p.GracePeriod)
+}
+
+// Expired report whether the credentials are no longer valid, that is neither the
+// current credentials nor the previous ones within the grace period.
+func (p *RotatingProvider) Expired(credentials *Credentials) bool {
+    p.mu.RLock()
+    defer p.mu.RUnlock()
+    if sameCredentials(p.current, credentials) {
+        return false
+    }
+    return !sameCredentials(p.previous, credentials) || time.Now().After(p.expiry)
+}
+
+// Refresh retrieve the credentials of source every interval and rotate when they change
+// until the context is done. Use a FileProvider as source to watch a credentials file.
+// A failed retrieval keep the current credentials and is reported to onError if not nil.
+func (p *RotatingProvider) Refresh(ctx context.Context, interval time.Duration, source CredentialsProvider, onError func(error)) {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        credentials, err := source.Retrieve(ctx)
+        if err == nil {
+            p.Rotate(credentials)
+        } else if onError != nil && !errors.Is(err, context.Canceled) {
+            onError(err)
+        }
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// sameCredentials report whether both credentials have the same keys
+func sameCredentials(a, b *Credentials) bool {
+    if a == nil || b == nil {
+        return a == b
+    }
+    return a.ApiKey == b.ApiKey && bytes.Equal(a.SecretKey, b.SecretKey)
+}
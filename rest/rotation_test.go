@@ -0,0 +1,128 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strconv"
+    "sync"
+    "time"
+)
+
+func TestRotatingProviderRetry(t *testing.T) {
+    secret, _ := GetSecretKeyAsByte(secretKey)
+    oldKey := &Credentials{ApiKey: "old", SecretKey: secret}
+    newKey := &Credentials{ApiKey: "new", SecretKey: secret}
+    for _, grace := range []time.Duration{time.Minute, 0} {
+        provider := NewRotatingProvider(oldKey)
+        provider.GracePeriod = grace
+        var keys []string
+        server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            key, _, _ := ParseAuthorization(r.Header.Get(Authorization))
+            keys = append(keys, key)
+            if len(keys) == 1 {
+                // rotate while the request is retried
+                provider.Rotate(newKey)
+                w.WriteHeader(http.StatusServiceUnavailable)
+            }
+        }))
+        client := NewRestClient(NewKeyConfig(""))
+        client.SetCredentialsProvider(provider)
+        client.SetRetryPolicy(&RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond})
+        req, _ := http.NewRequest("GET", server.URL, nil)
+        resp, err := client.Do(req)
+        server.Close()
+        if err != nil {
+            t.Fatal(err)
+        }
+        resp.Body.Close()
+        expect := "old"
+        if grace == 0 {
+            expect = "new"
+        }
+        if len(keys) != 2 || keys[0] != "old" || keys[1] != expect {
+            t.Error("grace period", grace, "expect retry signed by", expect, "got", keys)
+        }
+    }
+}
+
+func TestRotatingProviderConcurrent(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+    defer server.Close()
+    secret, _ := GetSecretKeyAsByte(secretKey)
+    provider := NewRotatingProvider(&Credentials{ApiKey: "key-0", SecretKey: secret})
+    client := NewRestClient(NewKeyConfig(""))
+    client.SetCredentialsProvider(provider)
+    var wg sync.WaitGroup
+    for i := 0; i < 8; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for j := 0; j < 10; j++ {
+                req, _ := http.NewRequest("GET", server.URL, nil)
+                if resp, err := client.Do(req); err != nil {
+                    t.Error(err)
+                } else {
+                    resp.Body.Close()
+                }
+            }
+        }()
+    }
+    for i := 1; i <= 10; i++ {
+        provider.Rotate(&Credentials{ApiKey: "key-" + strconv.Itoa(i), SecretKey: secret})
+        client.SetConfig(NewKeyConfig(""))
+    }
+    wg.Wait()
+}
+
+func TestRotatingProviderRefresh(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "credentials")
+    // replace the file atomically so the provider never read a partial file
+    write := func(key string, modTime time.Time) {
+        tmp := path + ".tmp"
+        os.WriteFile(tmp, []byte("[default]\napikey = "+key+"\nsecretkey = "+secretKey+"\n"), 0600)
+        os.Chtimes(tmp, modTime, modTime)
+        os.Rename(tmp, path)
+    }
+    write("first", time.Now().Add(-time.Hour))
+    provider := NewRotatingProvider(nil)
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan struct{})
+    defer func() {
+        cancel()
+        <-done
+    }()
+    go func() {
+        defer close(done)
+        provider.Refresh(ctx, 5*time.Millisecond, NewFileProvider(path, ""), func(err error) { t.Error(err) })
+    }()
+    waitKey := func(expect string) {
+        deadline := time.Now().Add(time.Second)
+        for time.Now().Before(deadline) {
+            if credentials, _ := provider.Retrieve(ctx); credentials != nil && credentials.ApiKey == expect {
+                return
+            }
+            time.Sleep(5 * time.Millisecond)
+        }
+        t.Fatal("credentials were not refreshed to", expect)
+    }
+    waitKey("first")
+    write("second", time.Now())
+    waitKey("second")
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "bytes"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "sort"
+    "strings"
+)
+
+// SignatureScheme is the version of the canonical string-to-sign of a request
+type SignatureScheme int
+
+const (
+    // SignatureV1 sign the body md5, the content type, the date, the X-Placenext-*
+    // header and the url as is. It is the default scheme.
+    SignatureV1 SignatureScheme = 1
+    // SignatureV2 sign the method, the RFC 9530 sha-256 Content-Digest of the body, the
+    // content type, the date, the X-Placenext-* header and the url with a canonical
+    // sorted query string. It does not use md5.
+    SignatureV2 SignatureScheme = 2
+)
+
+// ErrUnknownSignatureScheme is returned for a signature scheme that is not supported
+var ErrUnknownSignatureScheme = errors.New("signature scheme is unknown")
+
+// ErrMalformedQuery is returned for a url which query cannot be parsed, it is not signed
+// as part of it would be left out of the canonical url
+var ErrMalformedQuery = errors.New("url query is malformed")
+
+// authorization prefix of each scheme
+const (
+    authorizationV1 = "AimMatic "
+    authorizationV2 = "AimMatic-V2 "
+)
+
+// String return the name of the scheme
+func (s SignatureScheme) String() string {
+    switch s {
+    case SignatureV1:
+        return "v1"
+    case SignatureV2:
+        return "v2"
+    }
+    return "unknown"
+}
+
+// ParseSignatureScheme parse the name of a scheme, v1 or v2
+func ParseSignatureScheme(name string) (SignatureScheme, error) {
+    switch strings.ToLower(name) {
+    case "v1", "1":
+        return SignatureV1, nil
+    case "v2", "2":
+        return SignatureV2, nil
+    }
+    return 0, fmt.Errorf("%w: %s", ErrUnknownSignatureScheme, name)
+}
+
+// SignatureSchemeConfig is implemented by a Config that select its signature scheme.
+// A Config that does not implement it sign with SignatureV1.
+type SignatureSchemeConfig interface {
+    GetSignatureScheme() SignatureScheme
+}
+
+// signatureSchemeOf return the signature scheme of the config
+func signatureSchemeOf(config Config) SignatureScheme {
+    if c, ok := config.(SignatureSchemeConfig); ok {
+        if scheme := c.GetSignatureScheme(); scheme != 0 {
+            return scheme
+        }
+    }
+    return SignatureV1
+}
+
+// ContentDigestSHA256 return the RFC 9530 Content-Digest header value of a sha-256 digest
+func ContentDigestSHA256(sum []byte) string {
+    return "sha-256=:" + base64.StdEncoding.EncodeToString(sum) + ":"
+}
+
+// parseQuery parse the raw query, it return ErrMalformedQuery if any parameter is malformed
+func parseQuery(rawQuery string) (url.Values, error) {
+    values, err := url.ParseQuery(rawQuery)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrMalformedQuery, err)
+    }
+    return values, nil
+}
+
+// canonicalQuery sort the query parameters by name then value and escape them the same way
+func canonicalQuery(rawQuery string) (string, error) {
+    values, err := parseQuery(rawQuery)
+    if err != nil {
+        return "", err
+    }
+    keys := make([]string, 0, len(values))
+    for k := range values {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    var buf strings.Builder
+    for _, k := range keys {
+        vs := append([]string(nil), values[k]...)
+        sort.Strings(vs)
+        for _, v := range vs {
+            if buf.Len() > 0 {
+                buf.WriteByte('&')
+            }
+            buf.WriteString(strings.ReplaceAll(url.QueryEscape(k), "+", "%20"))
+            buf.WriteByte('=')
+            buf.WriteString(strings.ReplaceAll(url.QueryEscape(v), "+", "%20"))
+        }
+    }
+    return buf.String(), nil
+}
+
+// canonicalURL return the scheme, host and path without trailing slash of the request
+// followed by its canonical query string if any
+func canonicalURL(r *http.Request) (string, error) {
+    return canonicalURLOf(requestScheme(r), requestHost(r), r.URL.EscapedPath(), r.URL.RawQuery)
+}
+
+// canonicalURLOf return the canonical url of the given parts
+func canonicalURLOf(scheme, host, escapedPath, rawQuery string) (string, error) {
+    query, err := canonicalQuery(rawQuery)
+    if err != nil {
+        return "", err
+    }
+    u := scheme + "://" + host + strings.TrimSuffix(escapedPath, "/")
+    if query != "" {
+        u += "?" + query
+    }
+    return u, nil
+}
+
+// requestScheme return the scheme of the request url, X-Forwarded-Proto or the connection
+func requestScheme(r *http.Request) string {
+    if r.URL.Scheme != "" {
+        return r.URL.Scheme
+    }
+    if scheme := r.Header.Get(XForwardedProto); scheme != "" {
+        return scheme
+    }
+    if r.TLS != nil {
+        return "https"
+    }
+    return "http"
+}
+
+// requestHost return the host of the request url or the Host header
+func requestHost(r *http.Request) string {
+    if r.URL.Host != "" {
+        return r.URL.Host
+    }
+    return r.Host
+}
+
+// StringToSignV2 build the canonical string of the request signed with SignatureV2 and
+// return it with the Content-Digest header value if the request has a body. It is made
+// of the lines: method, content digest, content type, date, one line per X-Placenext-*
+// header and the canonical url. An empty content digest or content type is an empty line.
+// The request header is not modified. It return ErrMalformedQuery if the url query
+// cannot be parsed.
+func StringToSignV2(r *http.Request) (stringToSign []byte, contentDigest string, err error) {
+    date := r.Header.Get(XPlacenextDate)
+    if date == "" {
+        date = r.Header.Get(Date)
+    }
+    if date == "" {
+        return nil, "", ErrMissingDate
+    }
+    u, err := canonicalURL(r)
+    if err != nil {
+        return nil, "", err
+    }
+    if sum := ComputeBodySHA256(r); sum != nil {
+        contentDigest = ContentDigestSHA256(sum)
+    }
+    buf := bytes.NewBuffer(nil)
+    buf.WriteString(r.Method)
+    buf.WriteByte('\n')
+    buf.WriteString(contentDigest)
+    buf.WriteByte('\n')
+    buf.WriteString(r.Header.Get(ContentType))
+    buf.WriteByte('\n')
+    buf.WriteString(date)
+    buf.WriteByte('\n')
+    for _, line := range headerLines(r) {
+        buf.WriteString(line)
+        buf.WriteByte('\n')
+    }
+    buf.WriteString(u)
+    return buf.Bytes(), contentDigest, nil
+}
+
+// ComputeSignatureV2 calculate the hmac-sha256 signature of the request with SignatureV2
+func ComputeSignatureV2(r *http.Request, secret []byte) (signature []byte, signatureB64, contentDigest string, err error) {
+    var stringToSign []byte
+    if stringToSign, contentDigest, err = StringToSignV2(r); err != nil {
+        return
+    }
+    hm := hmac.New(sha256.New, secret)
+    hm.Write(stringToSign)
+    signature = hm.Sum(nil)
+    signatureB64 = base64.RawStdEncoding.EncodeToString(signature)
+    return
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "context"
+    "crypto/md5"
+    "crypto/sha256"
+    "errors"
+    "hash"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "time"
+)
+
+func TestParseSignatureScheme(t *testing.T) {
+    if scheme, err := ParseSignatureScheme("V2"); err != nil || scheme != SignatureV2 {
+        t.Error("expect v2 got", scheme, err)
+    }
+    if _, err := ParseSignatureScheme("v3"); !errors.Is(err, ErrUnknownSignatureScheme) {
+        t.Error("expect unknown scheme got", err)
+    }
+    config, _ := NewConfig(apiKey, secretKey)
+    if scheme := signatureSchemeOf(config); scheme != SignatureV1 {
+        t.Error("expect v1 by default got", scheme)
+    }
+    config, _ = NewConfig(apiKey, secretKey, WithSignatureScheme(SignatureV2))
+    if scheme := signatureSchemeOf(config); scheme != SignatureV2 {
+        t.Error("expect v2 got", scheme)
+    }
+}
+
+func TestStringToSignV2(t *testing.T) {
+    newRequest := func(url string) *http.Request {
+        req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+        req.Header.Set(ContentType, MediaJson)
+        req.Header.Set(XPlacenextDate, "Mon, 02 Jan 2006 15:04:05 UTC")
+        req.Header.Add("X-Placenext-Tag", "b")
+        req.Header.Add("X-Placenext-Tag", "a")
+        return req
+    }
+    req := newRequest("https://api.aimmatic.com/v1/points/?b=2&a=1&a=0&q=a+b")
+    sts, digest, err := StringToSignV2(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    sum := sha256.Sum256([]byte(body))
+    if expect := ContentDigestSHA256(sum[:]); digest != expect {
+        t.Error("expect digest", expect, "got", digest)
+    }
+    expect := "POST\n" + digest + "\n" + MediaJson + "\nMon, 02 Jan 2006 15:04:05 UTC\n" +
+        "x-placenext-date:Mon, 02 Jan 2006 15:04:05 UTC\nx-placenext-tag:a,b\n" +
+        "https://api.aimmatic.com/v1/points?a=0&a=1&b=2&q=a%20b"
+    if string(sts) != expect {
+        t.Errorf("expect string to sign\n%s\ngot\n%s", expect, sts)
+    }
+    // the header values are not sorted in place
+    if tags := req.Header["X-Placenext-Tag"]; tags[0] != "b" || tags[1] != "a" {
+        t.Error("expect header to be unchanged got", tags)
+    }
+    // the body can still be read
+    if b, _ := ioutil.ReadAll(req.Body); string(b) != body {
+        t.Error("expect body to be readable got", string(b))
+    }
+    // the query order does not change the signature
+    secret, _ := GetSecretKeyAsByte(secretKey)
+    _, s1, _, _ := ComputeSignatureV2(newRequest("https://api.aimmatic.com/v1/points?a=1&b=2"), secret)
+    _, s2, _, _ := ComputeSignatureV2(newRequest("https://api.aimmatic.com/v1/points?b=2&a=1"), secret)
+    if s1 != s2 {
+        t.Error("expect the same signature got", s1, s2)
+    }
+    // a malformed query is not signed partially
+    for _, u := range []string{"https://api.aimmatic.com/v1/points?a=1;b=2", "https://api.aimmatic.com/v1/points?a=%zz"} {
+        if _, _, err = StringToSignV2(newRequest(u)); !errors.Is(err, ErrMalformedQuery) {
+            t.Error("expect malformed query got", u, err)
+        }
+        if _, _, _, err = ComputeSignatureV2(newRequest(u), secret); !errors.Is(err, ErrMalformedQuery) {
+            t.Error("expect malformed query got", u, err)
+        }
+    }
+}
+
+func TestSignatureV2Request(t *testing.T) {
+    secret, _ := GetSecretKeyAsByte(secretKey)
+    var now time.Time
+    // a new verifier per request so identical requests are not replays
+    verify := func(req *http.Request) (string, error) {
+        verifier := NewVerifier(StaticKeyStore{apiKey: secret})
+        verifier.Now = func() time.Time { return now }
+        return verifier.Verify(req)
+    }
+    config, _ := NewConfig(apiKey, secretKey, WithSignatureScheme(SignatureV2))
+    // a streamed body signed with v2 never compute its md5
+    md5Computed := false
+    source := func(w io.Writer) error {
+        if h, ok := w.(hash.Hash); ok && h.Size() == md5.Size {
+            md5Computed = true
+        }
+        _, err := io.WriteString(w, body)
+        return err
+    }
+    newRequest := func() *http.Request {
+        md5Computed = false
+        req, err := NewStreamingRequest(context.Background(), "POST", "http://api.aimmatic.com/v1/placeNextIngest/PointImport?b=2&a=1", SignatureV2, source)
+        if err != nil {
+            t.Fatal(err)
+        }
+        req.Header.Set(ContentType, MediaJson)
+        if err = signRequest(req, config.GetApiKey(), NewHMACSigner(config.GetSecretKey()), time.Now(), signatureSchemeOf(config)); err != nil {
+            t.Fatal(err)
+        }
+        now, _ = time.Parse(time.RFC1123, req.Header.Get(XPlacenextDate))
+        // what the server receive
+        server := httptest.NewRequest("POST", req.URL.String(), req.Body)
+        server.Header = req.Header
+        return server
+    }
+    req := newRequest()
+    if auth := req.Header.Get(Authorization); !strings.HasPrefix(auth, "AimMatic-V2 "+apiKey+":") {
+        t.Error("expect v2 authorization got", auth)
+    }
+    if req.Header.Get(ContentMD5) != "" || req.Header.Get(ContentDigest) == "" {
+        t.Error("expect only a content digest got", req.Header)
+    }
+    if md5Computed {
+        t.Error("expect md5 not to be computed")
+    }
+    if key, err := verify(req); err != nil || key != apiKey {
+        t.Error("expect v2 request to be verified got", key, err)
+    }
+    // tampered body
+    req = newRequest()
+    req.Body = ioutil.NopCloser(strings.NewReader(strings.ToUpper(body)))
+    if _, err := verify(req); err != ErrContentDigestMismatch {
+        t.Error("expect content digest mismatch got", err)
+    }
+    // reordered query is still valid
+    req = newRequest()
+    req.URL.RawQuery = "a=1&b=2"
+    if _, err := verify(req); err != nil {
+        t.Error("expect reordered query to be verified got", err)
+    }
+}
@@ -17,10 +17,9 @@ limitations under the License.
 package rest
 
 import (
-    "log"
     "strings"
-    "sort"
     "net/http"
+    "io"
     "io/ioutil"
     "bytes"
     "errors"
@@ -28,6 +27,7 @@ import (
     "crypto/md5"
     "crypto/hmac"
     "crypto/sha256"
+    "hash"
 )
 
 // ErrMissingContentType an error indicate the request does not have content-type header
@@ -36,46 +36,9 @@ var ErrMissingContentType = errors.New("content-type header is not available")
 // ErrMissingDate an error indicate the request does not have date or x-placenext-date header
 var ErrMissingDate = errors.New("x-placenext-date or date header is not available")
 
-// temporary struct to store key of map use to sort key order
-type keyOrder struct {
-    key string
-    val string
-}
-
-// slide of key order
-type keyOrderSlice []*keyOrder
-
-func (ks keyOrderSlice) Len() int           { return len(ks) }
-func (ks keyOrderSlice) Less(i, j int) bool { return ks[i].key < ks[j].key }
-func (ks keyOrderSlice) Swap(i, j int)      { ks[i], ks[j] = ks[j], ks[i] }
-
 // create a sorted and concatenate header for all X-Placenext header
 func concatenateHeader(r *http.Request) string {
-    allHeader := map[string][]string(r.Header)
-    size := len(allHeader)
-    keyOrderSlice := make(keyOrderSlice, 0, size)
-    for k := range allHeader {
-        if strings.HasPrefix(k, "X-Placenext") {
-            lk := strings.ToLower(k)
-            allVal := ""
-            if len(allHeader[k]) > 1 {
-                sort.Strings(allHeader[k])
-                allVal = strings.Join(allHeader[k], ",")
-            } else {
-                allVal = allHeader[k][0]
-            }
-            keyOrderSlice = append(keyOrderSlice, &keyOrder{
-                key: lk,
-                val: lk + ":" + allVal,
-            })
-        }
-    }
-    sort.Sort(keyOrderSlice)
-    concat := ""
-    for i := range keyOrderSlice {
-        concat += keyOrderSlice[i].val
-    }
-    return concat
+    return strings.Join(headerLines(r), "")
 }
 
 // GetSecretKeyAsByte will decode secret key from string base 64 (no padding)
@@ -85,77 +48,76 @@ func GetSecretKeyAsByte(secret string) (secretByte []byte, err error) {
     return
 }
 
-// ComputeBodyMd5Base64 read the whole body of the request and calculate hash md5
-// if the content body is empty then the md5 of empty byte array will return
+// ComputeBodyMd5Base64 calculate hash md5 of the request body. A digest set with
+// SetBodyDigest or NewStreamingRequest is used as is, a body that can be replayed with
+// GetBody is hashed while it is read, otherwise the whole body is read and buffered.
+// if the content body is empty then nil is returned
 func ComputeBodyMd5Base64(r *http.Request) ([]byte) {
-    // calculate body md5
-    if r.ContentLength > 0 {
-        var buf bytes.Buffer
-        buf.ReadFrom(r.Body)
-        r.Body = ioutil.NopCloser(&buf)
-        bmd5 := md5.Sum(buf.Bytes())
-        return bmd5[:]
-    } else {
+    if body, ok := r.Body.(contentMD5er); ok {
+        if sum := body.ContentMD5(); sum != nil {
+            return sum
+        }
+    }
+    return hashBody(r, md5.New)
+}
+
+// ComputeBodySHA256 calculate hash sha-256 of the request body the same way as
+// ComputeBodyMd5Base64, a digest set with SetContentDigest is used as is.
+// if the content body is empty then nil is returned
+func ComputeBodySHA256(r *http.Request) []byte {
+    if body, ok := r.Body.(contentSHA256er); ok {
+        if sum := body.ContentSHA256(); sum != nil {
+            return sum
+        }
+    }
+    return hashBody(r, sha256.New)
+}
+
+// hashBody hash the request body, the body is buffered if it cannot be replayed
+func hashBody(r *http.Request, newHash func() hash.Hash) []byte {
+    if r.ContentLength <= 0 {
         return nil
     }
+    if r.GetBody != nil {
+        if body, err := r.GetBody(); err == nil {
+            h := newHash()
+            _, err = io.Copy(h, body)
+            body.Close()
+            if err == nil {
+                return h.Sum(nil)
+            }
+        }
+    }
+    // calculate body hash
+    var buf bytes.Buffer
+    buf.ReadFrom(r.Body)
+    r.Body = ioutil.NopCloser(&buf)
+    h := newHash()
+    h.Write(buf.Bytes())
+    return h.Sum(nil)
 }
 
 // ComputeSignature calculate hash result from the given request based on the secret key
 // The ComputeSignature use Hmac with sha256 standard hash function to calculate signature of the current request.
 func ComputeSignature(r *http.Request, secret []byte) (signature, contentMD5 []byte, signatureB64, contentMD5B64 string, err error) {
-    // calculate body md5
-    buf := bytes.NewBuffer(nil)
-    contentMD5 = ComputeBodyMd5Base64(r)
-    if contentMD5 != nil {
-        contentMD5B64 = base64.RawStdEncoding.EncodeToString(contentMD5)
-        // write content md5
-        buf.WriteString(contentMD5B64)
-        buf.WriteByte('\n')
-    }
-    // write content type
-    if r.Header.Get("Content-Type") != "" {
-        buf.WriteString(r.Header.Get("Content-Type"))
-        buf.WriteByte('\n')
-    }
-    // write date
-    if r.Header.Get("X-Placenext-Date") != "" {
-        buf.WriteString(r.Header.Get("X-Placenext-Date"))
-        buf.WriteByte('\n')
-    } else if r.Header.Get("Date") != "" {
-        buf.WriteString(r.Header.Get("Date"))
-        buf.WriteByte('\n')
-    } else {
-        err = ErrMissingDate
+    var stringToSign []byte
+    if stringToSign, contentMD5, contentMD5B64, err = StringToSign(r); err != nil {
         return
     }
-    // write header concat
-    buf.WriteString(concatenateHeader(r))
-    buf.WriteByte('\n')
-    // write url
-    if r.URL.Scheme != "" {
-        buf.WriteString(r.URL.String())
-    } else {
-        url := r.Host + r.URL.String()
-        if strings.HasSuffix(url, "/") {
-            url = url[:len(url)-1]
-        }
-        var scheme string
-        if scheme = r.Header.Get(XForwardedProto); scheme == "" {
-            if r.TLS != nil {
-                scheme = "https"
-            } else {
-                scheme = "http"
-            }
-        }
-        buf.WriteString(scheme + "://" + url)
-    }
     // encode signature
     hm := hmac.New(sha256.New, secret)
-    if _, err = hm.Write(buf.Bytes()); err == nil {
-        signature = hm.Sum(nil)
-        signatureB64 = base64.RawStdEncoding.EncodeToString(signature)
-    } else {
-        log.Println("Warning hmac.Write produce err which is strange behavior")
-    }
+    hm.Write(stringToSign)
+    signature = hm.Sum(nil)
+    signatureB64 = base64.RawStdEncoding.EncodeToString(signature)
     return
 }
+
+// StringToSign build the canonical string of the request that is signed by ComputeSignature
+// and return it with the body md5 if the request has a body, see CanonicalRequest.
+func StringToSign(r *http.Request) (stringToSign, contentMD5 []byte, contentMD5B64 string, err error) {
+    var c *CanonicalRequest
+    if c, contentMD5, err = canonicalRequest(r); err != nil {
+        return
+    }
+    return c.Bytes(), contentMD5, c.ContentMD5, nil
+}
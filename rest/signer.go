@@ -0,0 +1,150 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "bufio"
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net"
+    "time"
+)
+
+// Signer compute the signature of the canonical string-to-sign of a request, see
+// StringToSign. A Signer allow the secret key to live outside of the application,
+// for instance in a signing agent.
+type Signer interface {
+    Sign(ctx context.Context, stringToSign []byte) ([]byte, error)
+}
+
+// HMACSigner sign with hmac-sha256 using a secret key held in memory
+type HMACSigner struct {
+    secret []byte
+}
+
+// NewHMACSigner create a Signer from the raw secret key, see GetSecretKeyAsByte
+func NewHMACSigner(secret []byte) *HMACSigner {
+    return &HMACSigner{secret: secret}
+}
+
+// Sign return the hmac-sha256 of the string to sign
+func (s *HMACSigner) Sign(ctx context.Context, stringToSign []byte) ([]byte, error) {
+    hm := hmac.New(sha256.New, s.secret)
+    hm.Write(stringToSign)
+    return hm.Sum(nil), nil
+}
+
+// ErrAgent is returned when the signing agent reply with an error
+var ErrAgent = errors.New("signing agent failed")
+
+// agentRequest is a line of json sent to the signing agent
+type agentRequest struct {
+    KeyId        string `json:"keyId,omitempty"`
+    StringToSign []byte `json:"stringToSign"`
+}
+
+// agentResponse is a line of json sent back by the signing agent
+type agentResponse struct {
+    Signature []byte `json:"signature,omitempty"`
+    Error     string `json:"error,omitempty"`
+}
+
+// AgentSigner sign by asking a local signing agent listening on a unix socket, so
+// the secret key never live in the application process. The agent receive a line
+// of json {"keyId":"...","stringToSign":"<base64>"} and reply with a line of json
+// {"signature":"<base64>"} or {"error":"..."}. See ServeAgent.
+type AgentSigner struct {
+    // SocketPath is the path of the agent unix socket
+    SocketPath string
+    // KeyId tell the agent which key to use, it can be empty if the agent hold a single key
+    KeyId string
+    // Timeout bound a signing exchange when the context has no deadline
+    Timeout time.Duration
+}
+
+// NewAgentSigner create a Signer talking to the agent listening on the given unix socket
+func NewAgentSigner(socketPath, keyId string) *AgentSigner {
+    return &AgentSigner{SocketPath: socketPath, KeyId: keyId, Timeout: 5 * time.Second}
+}
+
+// Sign send the string to sign to the agent and return its signature
+func (s *AgentSigner) Sign(ctx context.Context, stringToSign []byte) ([]byte, error) {
+    if _, ok := ctx.Deadline(); !ok && s.Timeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+        defer cancel()
+    }
+    var dialer net.Dialer
+    conn, err := dialer.DialContext(ctx, "unix", s.SocketPath)
+    if err != nil {
+        return nil, err
+    }
+    defer conn.Close()
+    if deadline, ok := ctx.Deadline(); ok {
+        conn.SetDeadline(deadline)
+    }
+    if err = json.NewEncoder(conn).Encode(&agentRequest{KeyId: s.KeyId, StringToSign: stringToSign}); err != nil {
+        return nil, err
+    }
+    resp := &agentResponse{}
+    if err = json.NewDecoder(conn).Decode(resp); err != nil {
+        return nil, err
+    }
+    if resp.Error != "" {
+        return nil, fmt.Errorf("%w: %s", ErrAgent, resp.Error)
+    }
+    return resp.Signature, nil
+}
+
+// ServeAgent serve signing requests of AgentSigner on the given listener until it
+// is closed. The signer for a key id is returned by signers, it return nil when
+// the key is unknown.
+func ServeAgent(l net.Listener, signers func(keyId string) Signer) error {
+    for {
+        conn, err := l.Accept()
+        if err != nil {
+            return err
+        }
+        go serveAgentConn(conn, signers)
+    }
+}
+
+// serveAgentConn answer the signing requests of a connection
+func serveAgentConn(conn net.Conn, signers func(keyId string) Signer) {
+    defer conn.Close()
+    scanner := bufio.NewScanner(conn)
+    scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+    encoder := json.NewEncoder(conn)
+    for scanner.Scan() {
+        req := &agentRequest{}
+        resp := &agentResponse{}
+        if err := json.Unmarshal(scanner.Bytes(), req); err != nil {
+            resp.Error = "invalid request " + err.Error()
+        } else if signer := signers(req.KeyId); signer == nil {
+            resp.Error = "unknown key " + req.KeyId
+        } else if resp.Signature, err = signer.Sign(context.Background(), req.StringToSign); err != nil {
+            resp.Error = err.Error()
+        }
+        if encoder.Encode(resp) != nil {
+            return
+        }
+    }
+}
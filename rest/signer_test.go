@@ -0,0 +1,78 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "context"
+    "errors"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+func TestAgentSigner(t *testing.T) {
+    // unix socket path must be short
+    dir, err := os.MkdirTemp("", "pn")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    socket := filepath.Join(dir, "agent.sock")
+    listener, err := net.Listen("unix", socket)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer listener.Close()
+    secret, _ := GetSecretKeyAsByte(secretKey)
+    go ServeAgent(listener, func(keyId string) Signer {
+        if keyId != apiKey {
+            return nil
+        }
+        return NewHMACSigner(secret)
+    })
+    // the server verify the signature with the secret key
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        _, _, signature, _, err := ComputeSignature(r, secret)
+        if err != nil || r.Header.Get(Authorization) != "AimMatic "+apiKey+":"+signature {
+            w.WriteHeader(http.StatusUnauthorized)
+        }
+    }))
+    defer server.Close()
+    // the client only hold the api key
+    client := NewRestClient(NewKeyConfig(apiKey))
+    client.SetSigner(NewAgentSigner(socket, apiKey))
+    req, _ := http.NewRequest("POST", server.URL, strings.NewReader(body))
+    resp, err := client.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        t.Error("expect signature to be accepted got", resp.StatusCode)
+    }
+    // the request is not signed with an empty secret key when the signer is not set
+    client = NewRestClient(NewKeyConfig(apiKey))
+    req, _ = http.NewRequest("POST", server.URL, strings.NewReader(body))
+    if _, err = client.Do(req); !errors.Is(err, ErrNotConfigured) {
+        t.Error("expect not configured got", err)
+    }
+    // unknown key
+    if _, err = NewAgentSigner(socket, "unknown").Sign(context.Background(), []byte("data")); !errors.Is(err, ErrAgent) {
+        t.Error("expect agent error got", err)
+    }
+}
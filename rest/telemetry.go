@@ -0,0 +1,171 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "errors"
+    "log/slog"
+    "net/http"
+    "time"
+
+    "github.com/aimmatic/aimmatic-go-sdk-placenext/telemetry"
+)
+
+// name of the instruments recorded by the client
+const (
+    // MetricRequests count the requests by operation and status
+    MetricRequests = "placenext.client.requests"
+    // MetricErrors count the requests that failed or had an unsuccessful status
+    MetricErrors = "placenext.client.errors"
+    // MetricRetries count the attempts after the first one
+    MetricRetries = "placenext.client.retries"
+    // MetricBytesSent count the bytes of the request bodies of every attempt
+    MetricBytesSent = "placenext.client.bytes_sent"
+    // MetricDuration is the histogram of the request duration in seconds include retries
+    MetricDuration = "placenext.client.duration"
+    // MetricPointsIngested count the points accepted by the api server
+    MetricPointsIngested = "placenext.client.points_ingested"
+)
+
+// WithTracer create a span per request, see Client.SetTracer
+func WithTracer(tracer telemetry.Tracer) Option {
+    return func(o *clientOptions) {
+        o.tracer = tracer
+    }
+}
+
+// WithMeter record the metrics of the requests, see Client.SetMeter
+func WithMeter(meter telemetry.Meter) Option {
+    return func(o *clientOptions) {
+        o.meter = meter
+    }
+}
+
+// WithPropagator write the trace context into the requests, see Client.SetPropagator
+func WithPropagator(propagator telemetry.Propagator) Option {
+    return func(o *clientOptions) {
+        o.propagator = propagator
+    }
+}
+
+// SetTracer create a span per request named after its method and endpoint path. The span
+// cover every attempt of the request and is a child of the span of the request context.
+// A nil tracer disable tracing.
+func (c *Client) SetTracer(tracer telemetry.Tracer) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.tracer = tracer
+}
+
+// Tracer return the tracer of the client or nil
+func (c *Client) Tracer() telemetry.Tracer {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.tracer
+}
+
+// SetMeter record the counters and histograms of the requests, see the Metric constants.
+// A nil meter disable metrics.
+func (c *Client) SetMeter(meter telemetry.Meter) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.meter = meter
+}
+
+// Meter return the meter of the client or nil
+func (c *Client) Meter() telemetry.Meter {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.meter
+}
+
+// SetPropagator set how the trace context is written into every attempt. It run before
+// the request is signed, so X-Placenext-* header it write are covered by the signature.
+// When nil, the w3c trace context is propagated if the client has a tracer.
+func (c *Client) SetPropagator(propagator telemetry.Propagator) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.propagator = propagator
+}
+
+// instrument start the span of the request and return the request bound to the span
+// with a function that end the span and record the metrics of the result
+func (c *Client) instrument(req *http.Request) (*http.Request, func(*http.Response, error)) {
+    c.mu.RLock()
+    tracer, meter := c.tracer, c.meter
+    c.mu.RUnlock()
+    if tracer == nil && meter == nil {
+        return req, func(*http.Response, error) {}
+    }
+    ctx := req.Context()
+    attrs := []telemetry.Attribute{
+        slog.String("http.method", req.Method),
+        slog.String("url.full", Endpoint(req)),
+        slog.String("operation", OperationFromContext(ctx)),
+    }
+    var span telemetry.Span
+    if tracer != nil {
+        ctx, span = tracer.Start(ctx, req.Method+" "+req.URL.Path, attrs...)
+        req = req.WithContext(ctx)
+    }
+    start := time.Now()
+    return req, func(resp *http.Response, err error) {
+        if err == nil && resp.StatusCode >= 400 {
+            err = errors.New(resp.Status)
+        }
+        if resp != nil {
+            attrs = append(attrs, slog.Int("http.status_code", resp.StatusCode))
+        }
+        if span != nil {
+            if resp != nil {
+                span.SetAttributes(slog.Int("http.status_code", resp.StatusCode))
+            }
+            if err != nil {
+                span.RecordError(err)
+            }
+            span.End()
+        }
+        if meter != nil {
+            meter.Counter(MetricRequests).Add(ctx, 1, attrs...)
+            if err != nil {
+                meter.Counter(MetricErrors).Add(ctx, 1, attrs...)
+            }
+            meter.Histogram(MetricDuration).Record(ctx, time.Since(start).Seconds(), attrs...)
+        }
+    }
+}
+
+// telemetryInterceptor is the built-in interceptor that propagate the trace context
+// and count the retries and bytes sent of every attempt
+func telemetryInterceptor(propagator telemetry.Propagator, meter telemetry.Meter) Interceptor {
+    return func(req *http.Request, next Exchange) (*http.Response, error) {
+        ctx := req.Context()
+        if propagator != nil {
+            propagator.Inject(ctx, req.Header)
+        }
+        if meter != nil {
+            attrs := []telemetry.Attribute{slog.String("operation", OperationFromContext(ctx))}
+            if AttemptFromContext(ctx) > 1 {
+                meter.Counter(MetricRetries).Add(ctx, 1, attrs...)
+            }
+            if req.ContentLength > 0 {
+                meter.Counter(MetricBytesSent).Add(ctx, req.ContentLength, attrs...)
+            }
+        }
+        return next(req)
+    }
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+
+    "github.com/aimmatic/aimmatic-go-sdk-placenext/telemetry"
+)
+
+// headerPropagator write the trace id in a placenext header so it is signed
+type headerPropagator struct{}
+
+func (headerPropagator) Inject(ctx context.Context, header http.Header) {
+    telemetry.TraceContext{}.Inject(ctx, header)
+    header.Set("X-Placenext-Traceparent", header.Get("Traceparent"))
+}
+
+func TestClientTelemetry(t *testing.T) {
+    secret, _ := GetSecretKeyAsByte(secretKey)
+    var traceparents []string
+    calls := 0
+    verified := NewVerifier(StaticKeyStore{apiKey: secret}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        calls++
+        traceparents = append(traceparents, r.Header.Get("X-Placenext-Traceparent"))
+        if calls == 1 {
+            w.WriteHeader(http.StatusBadGateway)
+            return
+        }
+        verified.ServeHTTP(w, r)
+    }))
+    defer server.Close()
+    exporter := telemetry.NewMemoryExporter()
+    config, _ := NewConfig(apiKey, secretKey)
+    client := NewRestClient(config, WithTracer(exporter), WithMeter(exporter), WithPropagator(headerPropagator{}))
+    client.SetRetryPolicy(&RetryPolicy{MaxAttempts: 2})
+
+    ctx, parent := exporter.Start(context.Background(), "backfill")
+    req, _ := http.NewRequestWithContext(WithOperation(ctx, "PointImport"), http.MethodPost, server.URL+"/v1/placeNextIngest/PointImport", strings.NewReader(body))
+    req.Header.Set(ContentType, MediaJson)
+    resp, err := client.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    parent.End()
+    if resp.StatusCode != http.StatusOK {
+        t.Fatal("expect propagated header to be signed got", resp.StatusCode)
+    }
+
+    spans := exporter.Spans()
+    if len(spans) != 2 || spans[0].Name != "POST /v1/placeNextIngest/PointImport" {
+        t.Fatal("expect a span per request got", spans)
+    }
+    span := spans[0]
+    if span.Parent != parent.SpanContext() || span.Err != nil {
+        t.Error("wrong span parent or error", span)
+    }
+    if status, ok := span.Attribute("http.status_code"); !ok || status.Value.Int64() != 200 {
+        t.Error("expect status attribute got", status)
+    }
+    for _, traceparent := range traceparents {
+        if traceparent != span.SpanContext.TraceParent() {
+            t.Error("expect trace context of the request span got", traceparent)
+        }
+    }
+    for name, expect := range map[string]float64{MetricRequests: 1, MetricErrors: 0, MetricRetries: 1, MetricBytesSent: float64(2 * len(body))} {
+        if sum := exporter.Sum(name); sum != expect {
+            t.Errorf("expect %s %v got %v", name, expect, sum)
+        }
+    }
+    if len(exporter.Measurements(MetricDuration)) != 1 {
+        t.Error("expect a duration measurement")
+    }
+}
@@ -0,0 +1,308 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a help rest http client include config, compute
+// authenticate signature and add necessary http header that required by
+// placenext api server
+package rest
+
+import (
+    "bytes"
+    "context"
+    "crypto/hmac"
+    "encoding/base64"
+    "errors"
+    "io"
+    "io/ioutil"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+)
+
+// verification errors
+var (
+    ErrMissingAuthorization   = errors.New("authorization header is not available")
+    ErrMalformedAuthorization = errors.New("authorization header is malformed")
+    ErrUnknownKey             = errors.New("api key is unknown")
+    ErrContentMD5Mismatch     = errors.New("content-md5 does not match the body")
+    ErrContentDigestMismatch  = errors.New("content-digest does not match the body")
+    ErrSignatureMismatch      = errors.New("signature does not match")
+    ErrClockSkew              = errors.New("request date is outside of the allowed clock skew")
+    ErrMalformedDate          = errors.New("request date is malformed")
+    ErrBodyTooLarge           = errors.New("request body is too large")
+    ErrReplay                 = errors.New("request signature was already used")
+)
+
+// DefaultMaxClockSkew is the default accepted difference between the request date and the server clock
+const DefaultMaxClockSkew = 5 * time.Minute
+
+// DefaultMaxBodySize is the default largest body read to verify a request
+const DefaultMaxBodySize = 64 << 20
+
+// KeyStore look up the raw secret key of an api key. It return ErrUnknownKey when
+// the api key does not exist.
+type KeyStore interface {
+    SecretKey(ctx context.Context, apiKey string) ([]byte, error)
+}
+
+// KeyStoreFunc is an adapter to use a function as a KeyStore
+type KeyStoreFunc func(ctx context.Context, apiKey string) ([]byte, error)
+
+// SecretKey call f(ctx, apiKey)
+func (f KeyStoreFunc) SecretKey(ctx context.Context, apiKey string) ([]byte, error) {
+    return f(ctx, apiKey)
+}
+
+// StaticKeyStore is a KeyStore of raw secret keys by api key
+type StaticKeyStore map[string][]byte
+
+// SecretKey return the secret key of the api key
+func (s StaticKeyStore) SecretKey(ctx context.Context, apiKey string) ([]byte, error) {
+    if secret, ok := s[apiKey]; ok {
+        return secret, nil
+    }
+    return nil, ErrUnknownKey
+}
+
+// Verifier authenticate requests signed with the AimMatic signature scheme.
+type Verifier struct {
+    // Keys look up the secret key of the request api key
+    Keys KeyStore
+    // MaxClockSkew is the accepted difference between the request date and Now.
+    // Zero use DefaultMaxClockSkew.
+    MaxClockSkew time.Duration
+    // MaxBodySize is the largest body read to check its digest, a larger body is
+    // rejected with ErrBodyTooLarge. Zero use DefaultMaxBodySize.
+    MaxBodySize int64
+    // Now return the current time, if nil time.Now is used
+    Now func() time.Time
+    // OnError write the response of a rejected request. If nil, 401 Unauthorized
+    // is written with the error message.
+    OnError func(w http.ResponseWriter, r *http.Request, err error)
+
+    // signatures seen within the clock skew window and their expiry
+    mu     sync.Mutex
+    seen   map[string]time.Time
+    pruned time.Time
+}
+
+// NewVerifier create a Verifier that look up secret keys in the given key store
+func NewVerifier(keys KeyStore) *Verifier {
+    return &Verifier{Keys: keys}
+}
+
+// apiKeyContextKey is the context key of the authenticated api key
+type apiKeyContextKey struct{}
+
+// APIKeyFromContext return the api key authenticated by Verifier middleware
+func APIKeyFromContext(ctx context.Context) (string, bool) {
+    apiKey, ok := ctx.Value(apiKeyContextKey{}).(string)
+    return apiKey, ok
+}
+
+// Middleware return a handler that verify the request signature before calling next.
+// The authenticated api key is available with APIKeyFromContext.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+    return v.middleware(v.Verify, next)
+}
+
+// middleware return a handler that authenticate the request with verify before calling next
+func (v *Verifier) middleware(verify func(r *http.Request) (string, error), next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        apiKey, err := verify(r)
+        if err != nil {
+            if v.OnError != nil {
+                v.OnError(w, r, err)
+            } else if err == ErrBodyTooLarge {
+                http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+            } else {
+                http.Error(w, err.Error(), http.StatusUnauthorized)
+            }
+            return
+        }
+        next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, apiKey)))
+    })
+}
+
+// ParseAuthorization split the authorization header "AimMatic apikey:signature" or
+// "AimMatic-V2 apikey:signature" and decode the signature
+func ParseAuthorization(auth string) (apiKey string, signature []byte, err error) {
+    apiKey, signature, _, err = parseAuthorization(auth)
+    return
+}
+
+// parseAuthorization split the authorization header and return its signature scheme
+func parseAuthorization(auth string) (apiKey string, signature []byte, scheme SignatureScheme, err error) {
+    if auth == "" {
+        return "", nil, 0, ErrMissingAuthorization
+    }
+    var credential string
+    switch {
+    case strings.HasPrefix(auth, authorizationV1):
+        credential, scheme = auth[len(authorizationV1):], SignatureV1
+    case strings.HasPrefix(auth, authorizationV2):
+        credential, scheme = auth[len(authorizationV2):], SignatureV2
+    default:
+        return "", nil, 0, ErrMalformedAuthorization
+    }
+    index := strings.LastIndexByte(credential, ':')
+    if index <= 0 {
+        return "", nil, 0, ErrMalformedAuthorization
+    }
+    if signature, err = base64.RawStdEncoding.DecodeString(credential[index+1:]); err != nil {
+        return "", nil, 0, ErrMalformedAuthorization
+    }
+    return credential[:index], signature, scheme, nil
+}
+
+// Verify authenticate the request and return its api key. Both signature schemes are
+// accepted. The body is read to check Content-MD5 or Content-Digest and is replaced so
+// it can be read again by the next handler. A body larger than MaxBodySize is rejected
+// before it is hashed.
+func (v *Verifier) Verify(r *http.Request) (string, error) {
+    apiKey, requestSignature, scheme, err := parseAuthorization(r.Header.Get(Authorization))
+    if err != nil {
+        return "", err
+    }
+    now := time.Now()
+    if v.Now != nil {
+        now = v.Now()
+    }
+    if err = v.checkDate(r, now); err != nil {
+        return "", err
+    }
+    secret, err := v.Keys.SecretKey(r.Context(), apiKey)
+    if err != nil {
+        return "", err
+    }
+    if err = v.readBody(r); err != nil {
+        return "", err
+    }
+    var signature []byte
+    if scheme == SignatureV2 {
+        var contentDigest string
+        if signature, _, contentDigest, err = ComputeSignatureV2(r, secret); err != nil {
+            return "", err
+        }
+        if r.Header.Get(ContentDigest) != contentDigest {
+            return "", ErrContentDigestMismatch
+        }
+    } else {
+        var contentMD5B64 string
+        if signature, _, _, contentMD5B64, err = ComputeSignature(r, secret); err != nil {
+            return "", err
+        }
+        if r.Header.Get(ContentMD5) != contentMD5B64 {
+            return "", ErrContentMD5Mismatch
+        }
+    }
+    if !hmac.Equal(signature, requestSignature) {
+        return "", ErrSignatureMismatch
+    }
+    if !v.remember(string(requestSignature), now) {
+        return "", ErrReplay
+    }
+    return apiKey, nil
+}
+
+// maxSkew return the accepted clock skew
+func (v *Verifier) maxSkew() time.Duration {
+    if v.MaxClockSkew > 0 {
+        return v.MaxClockSkew
+    }
+    return DefaultMaxClockSkew
+}
+
+// maxBodySize return the largest body read to verify a request
+func (v *Verifier) maxBodySize() int64 {
+    if v.MaxBodySize > 0 {
+        return v.MaxBodySize
+    }
+    return DefaultMaxBodySize
+}
+
+// readBody buffer the body up to MaxBodySize so it can be hashed and read again by
+// the next handler. It return ErrBodyTooLarge if the body is larger. The content length
+// of a body of unknown length, such as a chunked body, is set to the buffered length so
+// the body is covered by the signed digest.
+func (v *Verifier) readBody(r *http.Request) error {
+    if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+        return nil
+    }
+    max := v.maxBodySize()
+    if r.ContentLength > max {
+        return ErrBodyTooLarge
+    }
+    b, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, max))
+    r.Body.Close()
+    if err != nil {
+        var tooLarge *http.MaxBytesError
+        if errors.As(err, &tooLarge) {
+            return ErrBodyTooLarge
+        }
+        return err
+    }
+    r.ContentLength = int64(len(b))
+    r.Body = ioutil.NopCloser(bytes.NewReader(b))
+    r.GetBody = func() (io.ReadCloser, error) {
+        return ioutil.NopCloser(bytes.NewReader(b)), nil
+    }
+    return nil
+}
+
+// checkDate make sure the signed date is within the clock skew window
+func (v *Verifier) checkDate(r *http.Request, now time.Time) error {
+    value := r.Header.Get(XPlacenextDate)
+    if value == "" {
+        value = r.Header.Get(Date)
+    }
+    if value == "" {
+        return ErrMissingDate
+    }
+    date, err := time.Parse(time.RFC1123, value)
+    if err != nil {
+        if date, err = http.ParseTime(value); err != nil {
+            return ErrMalformedDate
+        }
+    }
+    if skew := now.Sub(date); skew > v.maxSkew() || skew < -v.maxSkew() {
+        return ErrClockSkew
+    }
+    return nil
+}
+
+// remember the signature until it fall out of the clock skew window, it return
+// false if the signature was already seen
+func (v *Verifier) remember(signature string, now time.Time) bool {
+    v.mu.Lock()
+    defer v.mu.Unlock()
+    if v.seen == nil {
+        v.seen = make(map[string]time.Time)
+    }
+    // prune expired signatures at most once a second
+    if now.Sub(v.pruned) > time.Second {
+        for s, expiry := range v.seen {
+            if now.After(expiry) {
+                delete(v.seen, s)
+            }
+        }
+        v.pruned = now
+    }
+    if expiry, ok := v.seen[signature]; ok && !now.After(expiry) {
+        return false
+    }
+    // a request is accepted while its date is within the skew on either side
+    v.seen[signature] = now.Add(2 * v.maxSkew())
+    return true
+}
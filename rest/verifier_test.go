@@ -0,0 +1,129 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+    "testing"
+    "net/http"
+    "net/http/httptest"
+    "io/ioutil"
+    "strings"
+    "time"
+)
+
+func TestVerifierMiddleware(t *testing.T) {
+    secret, _ := GetSecretKeyAsByte(secretKey)
+    verifier := NewVerifier(StaticKeyStore{apiKey: secret})
+    var now time.Time
+    verifier.Now = func() time.Time { return now }
+    handler := verifier.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if key, ok := APIKeyFromContext(r.Context()); !ok || key != apiKey {
+            t.Error("expect authenticated api key got", key)
+        }
+        if b, _ := ioutil.ReadAll(r.Body); string(b) != body {
+            t.Error("expect body to be readable got", string(b))
+        }
+    }))
+    config, _ := NewConfig(apiKey, secretKey)
+    // the tags make the signature of requests sent within the same second different
+    newRequest := func(tags ...string) *http.Request {
+        req := httptest.NewRequest("POST", "http://api.aimmatic.com/v1/placeNextIngest/PointImport", strings.NewReader(body))
+        req.Header.Set(ContentType, MediaJson)
+        for _, tag := range tags {
+            req.Header.Add("X-Placenext-Tag", tag)
+        }
+        if err := signRequest(req, config.GetApiKey(), NewHMACSigner(config.GetSecretKey()), time.Now(), signatureSchemeOf(config)); err != nil {
+            t.Fatal(err)
+        }
+        now, _ = time.Parse(time.RFC1123, req.Header.Get(XPlacenextDate))
+        // the server does not see the client GetBody
+        req.GetBody = nil
+        return req
+    }
+    serve := func(req *http.Request) (int, string) {
+        w := httptest.NewRecorder()
+        handler.ServeHTTP(w, req)
+        return w.Code, strings.TrimSpace(w.Body.String())
+    }
+    // valid request with a body at the limit then replay
+    verifier.MaxBodySize = int64(len(body))
+    req := newRequest()
+    replay := req.Clone(req.Context())
+    replay.Body = ioutil.NopCloser(strings.NewReader(body))
+    if code, msg := serve(req); code != http.StatusOK {
+        t.Error("expect valid request got", code, msg)
+    }
+    if _, msg := serve(replay); msg != ErrReplay.Error() {
+        t.Error("expect replay to be rejected got", msg)
+    }
+    // tampered body
+    req = newRequest()
+    req.Body = ioutil.NopCloser(strings.NewReader(strings.ToUpper(body)))
+    if _, msg := serve(req); msg != ErrContentMD5Mismatch.Error() {
+        t.Error("expect content md5 mismatch got", msg)
+    }
+    // chunked body covered by the signature
+    req = newRequest("chunked")
+    req.ContentLength = -1
+    req.TransferEncoding = []string{"chunked"}
+    if code, msg := serve(req); code != http.StatusOK {
+        t.Error("expect chunked body to be verified got", code, msg)
+    }
+    // chunked body that is not signed
+    unsigned := httptest.NewRequest("POST", "http://api.aimmatic.com/v1/placeNextIngest/PointImport", nil)
+    unsigned.Header.Set(ContentType, MediaJson)
+    if err := signRequest(unsigned, config.GetApiKey(), NewHMACSigner(config.GetSecretKey()), time.Now(), signatureSchemeOf(config)); err != nil {
+        t.Fatal(err)
+    }
+    unsigned.Body, unsigned.ContentLength = ioutil.NopCloser(strings.NewReader("forged body")), -1
+    if _, msg := serve(unsigned); msg != ErrContentMD5Mismatch.Error() {
+        t.Error("expect unsigned chunked body to be rejected got", msg)
+    }
+    // tampered header
+    req = newRequest()
+    req.Header.Set(ContentType, MediaGeoJson)
+    if _, msg := serve(req); msg != ErrSignatureMismatch.Error() {
+        t.Error("expect signature mismatch got", msg)
+    }
+    // clock skew
+    req = newRequest()
+    now = now.Add(DefaultMaxClockSkew + time.Second)
+    if _, msg := serve(req); msg != ErrClockSkew.Error() {
+        t.Error("expect clock skew got", msg)
+    }
+    // malformed date
+    req = newRequest()
+    req.Header.Set(XPlacenextDate, "yesterday")
+    if _, msg := serve(req); msg != ErrMalformedDate.Error() {
+        t.Error("expect malformed date got", msg)
+    }
+    // body larger than the limit, announced or not
+    verifier.MaxBodySize--
+    req = newRequest()
+    if code, msg := serve(req); code != http.StatusRequestEntityTooLarge || msg != ErrBodyTooLarge.Error() {
+        t.Error("expect body too large got", code, msg)
+    }
+    req = newRequest()
+    req.ContentLength = 1
+    if _, msg := serve(req); msg != ErrBodyTooLarge.Error() {
+        t.Error("expect body too large got", msg)
+    }
+    verifier.MaxBodySize = 0
+    // unknown key
+    req = newRequest()
+    req.Header.Set(Authorization, strings.Replace(req.Header.Get(Authorization), apiKey, "unknown", 1))
+    if _, msg := serve(req); msg != ErrUnknownKey.Error() {
+        t.Error("expect unknown key got", msg)
+    }
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package telemetry provides the tracing and metrics interfaces the placenext
+// client is instrumented with. They mirror the shape of OpenTelemetry so an
+// adapter to any tracing or metrics library is a few lines, and an in-memory
+// implementation is provided for tests.
+package telemetry
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+// SpanData is a finished span recorded by MemoryExporter
+type SpanData struct {
+    Name        string
+    SpanContext SpanContext
+    // Parent is the span context of the parent span, it is not valid for a root span
+    Parent     SpanContext
+    Attributes []Attribute
+    Err        error
+    Start      time.Time
+    End        time.Time
+}
+
+// Attribute return the value of the attribute of the given key
+func (s *SpanData) Attribute(key string) (Attribute, bool) {
+    for _, attr := range s.Attributes {
+        if attr.Key == key {
+            return attr, true
+        }
+    }
+    return Attribute{}, false
+}
+
+// Measurement is a value recorded by an instrument of MemoryExporter
+type Measurement struct {
+    Value      float64
+    Attributes []Attribute
+}
+
+// MemoryExporter is a Tracer and a Meter that keep the finished spans and the
+// measurements in memory so they can be inspected by tests
+type MemoryExporter struct {
+    mu           sync.Mutex
+    spans        []SpanData
+    measurements map[string][]Measurement
+}
+
+// NewMemoryExporter create an empty exporter
+func NewMemoryExporter() *MemoryExporter {
+    return &MemoryExporter{measurements: make(map[string][]Measurement)}
+}
+
+// Start a span recorded once it ends
+func (e *MemoryExporter) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+    var parent SpanContext
+    if span := SpanFromContext(ctx); span != nil {
+        parent = span.SpanContext()
+    }
+    span := &memorySpan{exporter: e, data: SpanData{
+        Name:        name,
+        SpanContext: newSpanContext(parent),
+        Parent:      parent,
+        Attributes:  append([]Attribute(nil), attrs...),
+        Start:       time.Now(),
+    }}
+    return ContextWithSpan(ctx, span), span
+}
+
+// Spans return the finished spans in the order they ended
+func (e *MemoryExporter) Spans() []SpanData {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    return append([]SpanData(nil), e.spans...)
+}
+
+// Counter return a counter recording into the exporter
+func (e *MemoryExporter) Counter(name string) Counter {
+    return &memoryInstrument{exporter: e, name: name}
+}
+
+// Histogram return a histogram recording into the exporter
+func (e *MemoryExporter) Histogram(name string) Histogram {
+    return &memoryInstrument{exporter: e, name: name}
+}
+
+// Measurements return the values recorded by the instrument of the given name
+func (e *MemoryExporter) Measurements(name string) []Measurement {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    return append([]Measurement(nil), e.measurements[name]...)
+}
+
+// Sum return the sum of the values recorded by the instrument of the given name
+func (e *MemoryExporter) Sum(name string) float64 {
+    sum := 0.0
+    for _, m := range e.Measurements(name) {
+        sum += m.Value
+    }
+    return sum
+}
+
+// Reset forget the spans and measurements
+func (e *MemoryExporter) Reset() {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    e.spans = nil
+    e.measurements = make(map[string][]Measurement)
+}
+
+// record a measurement of the instrument
+func (e *MemoryExporter) record(name string, value float64, attrs []Attribute) {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    e.measurements[name] = append(e.measurements[name], Measurement{Value: value, Attributes: append([]Attribute(nil), attrs...)})
+}
+
+// memorySpan is a span of MemoryExporter
+type memorySpan struct {
+    exporter *MemoryExporter
+    mu       sync.Mutex
+    data     SpanData
+    ended    bool
+}
+
+func (s *memorySpan) SpanContext() SpanContext {
+    return s.data.SpanContext
+}
+
+func (s *memorySpan) SetAttributes(attrs ...Attribute) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.data.Attributes = append(s.data.Attributes, attrs...)
+}
+
+func (s *memorySpan) RecordError(err error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.data.Err = err
+}
+
+func (s *memorySpan) End() {
+    s.mu.Lock()
+    if s.ended {
+        s.mu.Unlock()
+        return
+    }
+    s.ended = true
+    s.data.End = time.Now()
+    data := s.data
+    s.mu.Unlock()
+    s.exporter.mu.Lock()
+    s.exporter.spans = append(s.exporter.spans, data)
+    s.exporter.mu.Unlock()
+}
+
+// memoryInstrument is a counter or a histogram of MemoryExporter
+type memoryInstrument struct {
+    exporter *MemoryExporter
+    name     string
+}
+
+func (i *memoryInstrument) Add(ctx context.Context, value int64, attrs ...Attribute) {
+    i.exporter.record(i.name, float64(value), attrs)
+}
+
+func (i *memoryInstrument) Record(ctx context.Context, value float64, attrs ...Attribute) {
+    i.exporter.record(i.name, value, attrs)
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package telemetry provides the tracing and metrics interfaces the placenext
+// client is instrumented with. They mirror the shape of OpenTelemetry so an
+// adapter to any tracing or metrics library is a few lines, and an in-memory
+// implementation is provided for tests.
+package telemetry
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "log/slog"
+    "net/http"
+    "strings"
+)
+
+// Attribute is a key value describing a span or a measurement
+type Attribute = slog.Attr
+
+// Tracer start spans
+type Tracer interface {
+    // Start a span that is a child of the span of ctx if any and return a copy
+    // of ctx that carry the new span
+    Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a unit of work
+type Span interface {
+    // SpanContext return the identifiers of the span
+    SpanContext() SpanContext
+    // SetAttributes add attributes to the span
+    SetAttributes(attrs ...Attribute)
+    // RecordError mark the span as failed with the given error
+    RecordError(err error)
+    // End the span, the span must not be used after
+    End()
+}
+
+// Meter create instruments
+type Meter interface {
+    Counter(name string) Counter
+    Histogram(name string) Histogram
+}
+
+// Counter is a monotonic sum
+type Counter interface {
+    Add(ctx context.Context, value int64, attrs ...Attribute)
+}
+
+// Histogram is a distribution of values
+type Histogram interface {
+    Record(ctx context.Context, value float64, attrs ...Attribute)
+}
+
+// SpanContext identify a span across process boundaries
+type SpanContext struct {
+    TraceID    [16]byte
+    SpanID     [8]byte
+    Sampled    bool
+    TraceState string
+}
+
+// IsValid report whether both trace id and span id are set
+func (sc SpanContext) IsValid() bool {
+    return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceParent return the w3c traceparent header value of the span context
+func (sc SpanContext) TraceParent() string {
+    flags := "00"
+    if sc.Sampled {
+        flags = "01"
+    }
+    return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+// ErrInvalidTraceParent is returned when a traceparent header cannot be parsed
+var ErrInvalidTraceParent = errors.New("telemetry: invalid traceparent")
+
+// ParseTraceParent parse a w3c traceparent header value
+func ParseTraceParent(value string) (SpanContext, error) {
+    var sc SpanContext
+    parts := strings.Split(value, "-")
+    if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+        return sc, ErrInvalidTraceParent
+    }
+    if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+        return sc, ErrInvalidTraceParent
+    }
+    if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+        return sc, ErrInvalidTraceParent
+    }
+    flags, err := hex.DecodeString(parts[3])
+    if err != nil || !sc.IsValid() {
+        return sc, ErrInvalidTraceParent
+    }
+    sc.Sampled = flags[0]&1 == 1
+    return sc, nil
+}
+
+// newSpanContext create a span context with a random span id, child of parent if valid
+func newSpanContext(parent SpanContext) SpanContext {
+    sc := SpanContext{Sampled: true}
+    if parent.IsValid() {
+        sc.TraceID, sc.Sampled, sc.TraceState = parent.TraceID, parent.Sampled, parent.TraceState
+    } else {
+        rand.Read(sc.TraceID[:])
+    }
+    rand.Read(sc.SpanID[:])
+    return sc
+}
+
+// spanKey is the context key of the current span
+type spanKey struct{}
+
+// ContextWithSpan return a copy of ctx that carry the span
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+    return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext return the span of ctx or nil
+func SpanFromContext(ctx context.Context) Span {
+    span, _ := ctx.Value(spanKey{}).(Span)
+    return span
+}
+
+// Propagator write the span context of ctx into the header of an outgoing request
+type Propagator interface {
+    Inject(ctx context.Context, header http.Header)
+}
+
+// TraceContext is the w3c trace context propagator writing traceparent and tracestate header
+type TraceContext struct{}
+
+// Inject write the traceparent and tracestate header of the span of ctx
+func (TraceContext) Inject(ctx context.Context, header http.Header) {
+    span := SpanFromContext(ctx)
+    if span == nil {
+        return
+    }
+    sc := span.SpanContext()
+    if !sc.IsValid() {
+        return
+    }
+    header.Set("Traceparent", sc.TraceParent())
+    if sc.TraceState != "" {
+        header.Set("Tracestate", sc.TraceState)
+    }
+}
+
+// Extract return the span context of the traceparent and tracestate header
+func (TraceContext) Extract(header http.Header) (SpanContext, error) {
+    sc, err := ParseTraceParent(header.Get("Traceparent"))
+    if err != nil {
+        return sc, err
+    }
+    sc.TraceState = header.Get("Tracestate")
+    return sc, nil
+}
+
+// remoteSpan is a span received from another process, it is only used as a parent
+type remoteSpan struct {
+    sc SpanContext
+}
+
+func (s remoteSpan) SpanContext() SpanContext {
+    return s.sc
+}
+
+func (s remoteSpan) SetAttributes(...Attribute) {}
+
+func (s remoteSpan) RecordError(error) {}
+
+func (s remoteSpan) End() {}
+
+// ContextWithRemoteSpanContext return a copy of ctx which span is the given remote
+// span, the next span started from ctx is its child
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+    return ContextWithSpan(ctx, remoteSpan{sc: sc})
+}
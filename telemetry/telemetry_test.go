@@ -0,0 +1,44 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package telemetry
+
+import (
+    "context"
+    "net/http"
+    "testing"
+)
+
+func TestTraceContext(t *testing.T) {
+    exporter := NewMemoryExporter()
+    remote, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+    if err != nil {
+        t.Fatal(err)
+    }
+    ctx, span := exporter.Start(ContextWithRemoteSpanContext(context.Background(), remote), "child")
+    header := http.Header{}
+    TraceContext{}.Inject(ctx, header)
+    span.End()
+    sc, err := TraceContext{}.Extract(header)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if sc.TraceID != remote.TraceID || sc.SpanID == remote.SpanID || !sc.Sampled || sc != span.SpanContext() {
+        t.Error("expect child of the remote span got", header.Get("Traceparent"))
+    }
+    for _, value := range []string{"", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "00-xyz-00f067aa0ba902b7-01"} {
+        if _, err = ParseTraceParent(value); err != ErrInvalidTraceParent {
+            t.Errorf("expect %q to be invalid", value)
+        }
+    }
+}
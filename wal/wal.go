@@ -0,0 +1,528 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package wal provides a durable file backed write-ahead queue. Records are
+// appended to segment files and stay on disk until they are acknowledged, so
+// pending records can be replayed after a crash or a restart.
+package wal
+
+import (
+    "bufio"
+    "crypto/rand"
+    "encoding/binary"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// ErrClosed is returned when the log is used after Close
+var ErrClosed = errors.New("wal: log is closed")
+
+// ErrRecordTooLarge is returned when a record exceed MaxRecordSize
+var ErrRecordTooLarge = errors.New("wal: record too large")
+
+// ErrCorrupted is returned when a segment other than the last one has an invalid record
+var ErrCorrupted = errors.New("wal: segment is corrupted")
+
+// SyncPolicy define when appended records are flushed to stable storage
+type SyncPolicy int
+
+const (
+    // SyncAlways fsync the segment after every append
+    SyncAlways SyncPolicy = iota
+    // SyncInterval fsync the segment periodically, see Options.SyncInterval
+    SyncInterval
+    // SyncNever leave flushing to the operating system
+    SyncNever
+)
+
+// default options
+const (
+    DefaultSegmentSize  = 16 << 20
+    DefaultSyncInterval = time.Second
+    MaxRecordSize       = 64 << 20
+)
+
+const (
+    segmentExt = ".wal"
+    ackFile    = "ack"
+    idFile     = "id"
+    // crc32 + length + sequence
+    headerSize = 4 + 4 + 8
+)
+
+// Options configure a Log, a zero value use the default
+type Options struct {
+    // SegmentSize start a new segment once the current one reach the given size in bytes
+    SegmentSize int64
+    // Sync policy of the appended records
+    Sync SyncPolicy
+    // SyncInterval is the fsync period of SyncInterval policy
+    SyncInterval time.Duration
+}
+
+// Record is an appended entry of the log
+type Record struct {
+    // Seq is the sequence number of the record, unique and increasing
+    Seq uint64
+    // Data is the record payload
+    Data []byte
+}
+
+// segment is a log file holding records from its first sequence number
+type segment struct {
+    path  string
+    first uint64
+    // last sequence number in the segment, only valid when the segment is not empty
+    last  uint64
+    empty bool
+}
+
+// Log is a segment based write-ahead log. A Log is safe for concurrent use.
+type Log struct {
+    dir  string
+    id   string
+    opts Options
+
+    mu       sync.Mutex
+    segments []*segment
+    file     *os.File
+    size     int64
+    nextSeq  uint64
+    // every record below watermark is acknowledged
+    watermark uint64
+    // acknowledged records at or above watermark
+    acked  map[uint64]bool
+    dirty  bool
+    closed bool
+    // broken is set when a failed append could not be undone, the log refuse appends
+    broken error
+    stop   chan struct{}
+    done   chan struct{}
+}
+
+// Open open or create a log in the given directory. Segments are scanned to
+// find the last record, a corrupted or partially written tail record is
+// truncated and fully acknowledged segments are removed.
+func Open(dir string, opts Options) (*Log, error) {
+    if opts.SegmentSize <= 0 {
+        opts.SegmentSize = DefaultSegmentSize
+    }
+    if opts.SyncInterval <= 0 {
+        opts.SyncInterval = DefaultSyncInterval
+    }
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, err
+    }
+    l := &Log{dir: dir, opts: opts, acked: make(map[uint64]bool)}
+    var err error
+    if l.id, err = readID(filepath.Join(dir, idFile)); err != nil {
+        return nil, err
+    }
+    if l.watermark, err = readAck(filepath.Join(dir, ackFile)); err != nil {
+        return nil, err
+    }
+    if err = l.load(); err != nil {
+        return nil, err
+    }
+    if err = l.compactLocked(); err != nil {
+        return nil, err
+    }
+    if err = l.openTail(); err != nil {
+        return nil, err
+    }
+    if opts.Sync == SyncInterval {
+        l.stop = make(chan struct{})
+        l.done = make(chan struct{})
+        go l.syncLoop()
+    }
+    return l, nil
+}
+
+// load scan every segment of the directory
+func (l *Log) load() error {
+    names, err := filepath.Glob(filepath.Join(l.dir, "*"+segmentExt))
+    if err != nil {
+        return err
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        first, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), segmentExt), 10, 64)
+        if err != nil {
+            continue
+        }
+        l.segments = append(l.segments, &segment{path: name, first: first, empty: true})
+    }
+    l.nextSeq = l.watermark
+    for i, seg := range l.segments {
+        // only the last segment can have a torn tail left by a crash
+        if err := scan(seg, i == len(l.segments)-1, nil); err != nil {
+            return err
+        }
+        if !seg.empty && seg.last+1 > l.nextSeq {
+            l.nextSeq = seg.last + 1
+        }
+    }
+    return nil
+}
+
+// scan read every valid record of the segment and call fn if not nil. When repair is
+// true the segment is truncated after the last valid record, otherwise an invalid
+// record return ErrCorrupted.
+func scan(seg *segment, repair bool, fn func(Record)) error {
+    f, err := os.Open(seg.path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    reader := bufio.NewReader(f)
+    var offset int64
+    header := make([]byte, headerSize)
+    for {
+        if _, err = io.ReadFull(reader, header); err != nil {
+            break
+        }
+        sum := binary.BigEndian.Uint32(header[0:4])
+        length := binary.BigEndian.Uint32(header[4:8])
+        seq := binary.BigEndian.Uint64(header[8:16])
+        if length > MaxRecordSize {
+            err = ErrRecordTooLarge
+            break
+        }
+        data := make([]byte, length)
+        if _, err = io.ReadFull(reader, data); err != nil {
+            break
+        }
+        if crc32.ChecksumIEEE(append(header[8:16:16], data...)) != sum {
+            err = errors.New("checksum mismatch")
+            break
+        }
+        offset += headerSize + int64(length)
+        seg.last = seq
+        seg.empty = false
+        if fn != nil {
+            fn(Record{Seq: seq, Data: data})
+        }
+    }
+    if err == io.EOF {
+        return nil
+    }
+    if repair {
+        return os.Truncate(seg.path, offset)
+    }
+    return fmt.Errorf("%w: %s at offset %d: %v", ErrCorrupted, seg.path, offset, err)
+}
+
+// openTail open the last segment for append or create a new one
+func (l *Log) openTail() error {
+    if len(l.segments) == 0 {
+        return l.rotateLocked()
+    }
+    seg := l.segments[len(l.segments)-1]
+    f, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_APPEND, 0644)
+    if err != nil {
+        return err
+    }
+    info, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return err
+    }
+    l.file, l.size = f, info.Size()
+    return nil
+}
+
+// rotateLocked close the current segment and start a new one
+func (l *Log) rotateLocked() error {
+    if l.file != nil {
+        if err := l.syncLocked(); err != nil {
+            return err
+        }
+        if err := l.file.Close(); err != nil {
+            return err
+        }
+    }
+    seg := &segment{path: filepath.Join(l.dir, fmt.Sprintf("%020d%s", l.nextSeq, segmentExt)), first: l.nextSeq, empty: true}
+    f, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+    if err != nil {
+        return err
+    }
+    l.segments = append(l.segments, seg)
+    l.file, l.size = f, 0
+    return syncDir(l.dir)
+}
+
+// ID return the random identifier of the log, it is created with the log directory
+// and never change
+func (l *Log) ID() string {
+    return l.id
+}
+
+// Append write a record to the log and return its sequence number. The record is
+// durable according to the sync policy.
+func (l *Log) Append(data []byte) (uint64, error) {
+    if len(data) > MaxRecordSize {
+        return 0, ErrRecordTooLarge
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.closed {
+        return 0, ErrClosed
+    }
+    if l.broken != nil {
+        return 0, l.broken
+    }
+    if l.size >= l.opts.SegmentSize {
+        if err := l.rotateLocked(); err != nil {
+            return 0, err
+        }
+    }
+    seq := l.nextSeq
+    buf := make([]byte, headerSize+len(data))
+    binary.BigEndian.PutUint32(buf[4:8], uint32(len(data)))
+    binary.BigEndian.PutUint64(buf[8:16], seq)
+    copy(buf[headerSize:], data)
+    binary.BigEndian.PutUint32(buf[0:4], crc32.ChecksumIEEE(buf[8:]))
+    if _, err := l.file.Write(buf); err != nil {
+        // drop the partial record so the following records stay readable
+        l.undoLocked()
+        return 0, err
+    }
+    l.dirty = true
+    if l.opts.Sync == SyncAlways {
+        if err := l.syncLocked(); err != nil {
+            // the record is not durable, drop it so its sequence number is not written twice
+            l.undoLocked()
+            return 0, err
+        }
+    }
+    l.nextSeq++
+    l.size += int64(len(buf))
+    seg := l.segments[len(l.segments)-1]
+    seg.last, seg.empty = seq, false
+    return seq, nil
+}
+
+// undoLocked truncate the segment back to its size before the failed append. If it
+// cannot be truncated the following appends would land at a wrong offset, so the log
+// refuse them.
+func (l *Log) undoLocked() {
+    if err := l.file.Truncate(l.size); err != nil {
+        l.broken = fmt.Errorf("wal: cannot undo a failed append: %w", err)
+    }
+}
+
+// Ack acknowledge the record of the given sequence number. Acknowledged records
+// are not replayed and their segments are removed once every record is acknowledged.
+// Only the contiguous acknowledged prefix is persisted, a record acknowledged out
+// of order may be replayed again after a restart.
+func (l *Log) Ack(seq uint64) error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.closed {
+        return ErrClosed
+    }
+    if seq < l.watermark || seq >= l.nextSeq {
+        return nil
+    }
+    l.acked[seq] = true
+    advanced := false
+    for l.acked[l.watermark] {
+        delete(l.acked, l.watermark)
+        l.watermark++
+        advanced = true
+    }
+    if !advanced {
+        return nil
+    }
+    if err := writeAck(filepath.Join(l.dir, ackFile), l.watermark); err != nil {
+        return err
+    }
+    return l.compactLocked()
+}
+
+// compactLocked remove every segment which records are all acknowledged except
+// the segment open for append
+func (l *Log) compactLocked() error {
+    keep := l.segments[:0]
+    for i, seg := range l.segments {
+        last := i == len(l.segments)-1
+        if !last && (seg.empty || seg.last < l.watermark) {
+            if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+                return err
+            }
+            continue
+        }
+        keep = append(keep, seg)
+    }
+    l.segments = keep
+    return nil
+}
+
+// Pending return every record which is not acknowledged yet in sequence order
+func (l *Log) Pending() ([]Record, error) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.closed {
+        return nil, ErrClosed
+    }
+    var records []Record
+    for _, seg := range l.segments {
+        if seg.empty || seg.last < l.watermark {
+            continue
+        }
+        err := scan(seg, false, func(r Record) {
+            if r.Seq >= l.watermark && !l.acked[r.Seq] {
+                records = append(records, r)
+            }
+        })
+        if err != nil {
+            return nil, err
+        }
+    }
+    return records, nil
+}
+
+// Sync flush the appended records to stable storage
+func (l *Log) Sync() error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.closed {
+        return ErrClosed
+    }
+    return l.syncLocked()
+}
+
+func (l *Log) syncLocked() error {
+    if !l.dirty {
+        return nil
+    }
+    if err := syncFile(l.file); err != nil {
+        return err
+    }
+    l.dirty = false
+    return nil
+}
+
+// syncFile fsync a segment, it is replaced in tests
+var syncFile = (*os.File).Sync
+
+// syncLoop fsync periodically for SyncInterval policy
+func (l *Log) syncLoop() {
+    defer close(l.done)
+    ticker := time.NewTicker(l.opts.SyncInterval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ticker.C:
+            l.Sync()
+        case <-l.stop:
+            return
+        }
+    }
+}
+
+// Close flush and close the log
+func (l *Log) Close() error {
+    l.mu.Lock()
+    if l.closed {
+        l.mu.Unlock()
+        return ErrClosed
+    }
+    l.closed = true
+    err := l.syncLocked()
+    if cerr := l.file.Close(); err == nil {
+        err = cerr
+    }
+    l.mu.Unlock()
+    if l.stop != nil {
+        close(l.stop)
+        <-l.done
+    }
+    return err
+}
+
+// readAck read the acknowledged watermark, a missing file means nothing is acknowledged
+func readAck(path string) (uint64, error) {
+    b, err := ioutil.ReadFile(path)
+    if os.IsNotExist(err) {
+        return 0, nil
+    } else if err != nil {
+        return 0, err
+    }
+    return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// writeAck atomically and durably replace the acknowledged watermark
+func writeAck(path string, watermark uint64) error {
+    return writeFileAtomic(path, []byte(strconv.FormatUint(watermark, 10)))
+}
+
+// writeFileAtomic atomically and durably replace the file: the temporary file is
+// synced before it is renamed and the directory is synced after
+func writeFileAtomic(path string, data []byte) error {
+    tmp := path + ".tmp"
+    f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+    if err != nil {
+        return err
+    }
+    if _, err = f.Write(data); err == nil {
+        err = f.Sync()
+    }
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+    if err != nil {
+        return err
+    }
+    if err = os.Rename(tmp, path); err != nil {
+        return err
+    }
+    return syncDir(filepath.Dir(path))
+}
+
+// readID read the id of the log or create it
+func readID(path string) (string, error) {
+    b, err := ioutil.ReadFile(path)
+    if err == nil {
+        return strings.TrimSpace(string(b)), nil
+    } else if !os.IsNotExist(err) {
+        return "", err
+    }
+    id := make([]byte, 16)
+    if _, err = rand.Read(id); err != nil {
+        return "", err
+    }
+    encoded := hex.EncodeToString(id)
+    return encoded, writeFileAtomic(path, []byte(encoded))
+}
+
+// syncDir fsync the directory so a new or renamed file survive a crash
+func syncDir(dir string) error {
+    d, err := os.Open(dir)
+    if err != nil {
+        return err
+    }
+    defer d.Close()
+    return d.Sync()
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2018 The AimMatic Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wal
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "strconv"
+    "testing"
+)
+
+func pendingSeqs(t *testing.T, l *Log) []uint64 {
+    records, err := l.Pending()
+    if err != nil {
+        t.Fatal(err)
+    }
+    seqs := make([]uint64, 0, len(records))
+    for _, r := range records {
+        if string(r.Data) != "record "+strconv.FormatUint(r.Seq, 10) {
+            t.Error("unexpected data", string(r.Data), "of record", r.Seq)
+        }
+        seqs = append(seqs, r.Seq)
+    }
+    return seqs
+}
+
+func TestLogReplay(t *testing.T) {
+    dir := t.TempDir()
+    l, err := Open(dir, Options{SegmentSize: 64, Sync: SyncAlways})
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 10; i++ {
+        seq, err := l.Append([]byte("record " + strconv.Itoa(i)))
+        if err != nil || seq != uint64(i) {
+            t.Fatal("append", i, "got", seq, err)
+        }
+    }
+    // acknowledge out of order
+    for _, seq := range []uint64{1, 0, 2, 3, 7} {
+        if err = l.Ack(seq); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if seqs := pendingSeqs(t, l); len(seqs) != 5 || seqs[0] != 4 || seqs[4] != 9 {
+        t.Error("unexpected pending records", seqs)
+    }
+    if err = l.Close(); err != nil {
+        t.Fatal(err)
+    }
+    // acknowledged segments are compacted
+    segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+    if len(segments) >= 5 {
+        t.Error("expect acknowledged segments to be removed got", segments)
+    }
+    // out of order ack is not persisted so record 7 is replayed
+    if l, err = Open(dir, Options{SegmentSize: 64}); err != nil {
+        t.Fatal(err)
+    }
+    defer l.Close()
+    if seqs := pendingSeqs(t, l); len(seqs) != 6 || seqs[0] != 4 || seqs[5] != 9 {
+        t.Error("unexpected pending records after reopen", seqs)
+    }
+    if seq, _ := l.Append([]byte("record 10")); seq != 10 {
+        t.Error("expect sequence to continue got", seq)
+    }
+}
+
+func TestLogCorruptedTail(t *testing.T) {
+    dir := t.TempDir()
+    l, err := Open(dir, Options{Sync: SyncNever})
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.Append([]byte("record 0"))
+    l.Append([]byte("record 1"))
+    l.Close()
+    // simulate a torn write of the last record
+    segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+    info, _ := os.Stat(segments[0])
+    os.Truncate(segments[0], info.Size()-3)
+    if l, err = Open(dir, Options{}); err != nil {
+        t.Fatal(err)
+    }
+    defer l.Close()
+    if seqs := pendingSeqs(t, l); len(seqs) != 1 || seqs[0] != 0 {
+        t.Error("expect corrupted record to be truncated got", seqs)
+    }
+    if seq, _ := l.Append([]byte("record 1")); seq != 1 {
+        t.Error("expect truncated sequence to be reused got", seq)
+    }
+    if seqs := pendingSeqs(t, l); len(seqs) != 2 {
+        t.Error("expect record after truncation to be readable got", seqs)
+    }
+}
+
+func TestLogSyncFailure(t *testing.T) {
+    dir := t.TempDir()
+    l, err := Open(dir, Options{Sync: SyncAlways})
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer l.Close()
+    l.Append([]byte("record 0"))
+    failed := errors.New("sync failed")
+    syncFile = func(f *os.File) error { return failed }
+    if _, err = l.Append([]byte("record 1")); err != failed {
+        t.Fatal("expect sync failure got", err)
+    }
+    syncFile = (*os.File).Sync
+    // the record that failed to sync is dropped and its sequence number reused
+    if seq, err := l.Append([]byte("record 1")); err != nil || seq != 1 {
+        t.Fatal("expect sequence to be reused got", seq, err)
+    }
+    if seqs := pendingSeqs(t, l); len(seqs) != 2 || seqs[1] != 1 {
+        t.Error("expect a single record per sequence got", seqs)
+    }
+}
+
+func TestLogCorruptedSegment(t *testing.T) {
+    dir := t.TempDir()
+    l, err := Open(dir, Options{SegmentSize: 32, Sync: SyncNever})
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i := 0; i < 4; i++ {
+        l.Append([]byte("record " + strconv.Itoa(i)))
+    }
+    l.Close()
+    // corrupt a record in a segment that is not the last one
+    segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+    if len(segments) < 2 {
+        t.Fatal("expect several segments got", segments)
+    }
+    b, _ := os.ReadFile(segments[0])
+    b[len(b)-1] ^= 0xff
+    os.WriteFile(segments[0], b, 0644)
+    if _, err = Open(dir, Options{}); !errors.Is(err, ErrCorrupted) {
+        t.Error("expect corrupted segment error got", err)
+    }
+    // the segment is not truncated
+    if info, _ := os.Stat(segments[0]); info.Size() != int64(len(b)) {
+        t.Error("expect corrupted segment to be kept got size", info.Size())
+    }
+}
+
+func TestLogID(t *testing.T) {
+    dir := t.TempDir()
+    l, err := Open(dir, Options{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    id := l.ID()
+    l.Close()
+    if l, err = Open(dir, Options{}); err != nil {
+        t.Fatal(err)
+    }
+    defer l.Close()
+    if id == "" || l.ID() != id {
+        t.Error("expect the id to be kept got", id, l.ID())
+    }
+    other, err := Open(t.TempDir(), Options{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer other.Close()
+    if other.ID() == id {
+        t.Error("expect a distinct id per log")
+    }
+}